@@ -2,6 +2,22 @@ package connector
 
 import (
 	"time"
+
+	catalog "github.com/nationaloilwellvarco/max-edge/lib-errors-go/catalog"
+)
+
+//errors returned by the connectors, see the error catalog
+var (
+	ErrNotConnected      = catalog.New("BRK-001", catalog.CategoryUnavailable, catalog.SeverityError, "broker not connected")
+	ErrAlreadyConnected  = catalog.New("BRK-002", catalog.CategoryConflict, catalog.SeverityWarn, "broker already connected")
+	ErrTopicEmpty        = catalog.New("BRK-003", catalog.CategoryValidation, catalog.SeverityError, "topic name is empty")
+	ErrTopicNotFoundf    = catalog.New("BRK-004", catalog.CategoryNotFound, catalog.SeverityWarn, "topic \"%s\" not found")
+	ErrTopicsUnavailable = catalog.New("BRK-005", catalog.CategoryUnavailable, catalog.SeverityError, "unable to get the topics of the broker")
+	ErrSubscribef        = catalog.New("BRK-006", catalog.CategoryUnavailable, catalog.SeverityError, "unable to subscribe to topic \"%s\"")
+	ErrPublishf          = catalog.New("BRK-007", catalog.CategoryUnavailable, catalog.SeverityError, "unable to publish to topic \"%s\"")
+	ErrEncodef           = catalog.New("BRK-008", catalog.CategoryValidation, catalog.SeverityError, "unable to encode message for topic \"%s\"")
+	ErrTLSConfig         = catalog.New("BRK-009", catalog.CategoryValidation, catalog.SeverityError, "invalid TLS certificates")
+	ErrDisconnect        = catalog.New("BRK-010", catalog.CategoryUnavailable, catalog.SeverityError, "unable to disconnect from the broker")
 )
 
 type (
@@ -10,15 +26,16 @@ type (
 		Disconnect() error
 		Subscribe(topicName string,
 			handle func(message interface{}, subject string),
-			opts *SubscriptionOptions),decode Decode) (msg interface{},error)
+			opts *SubscriptionOptions,
+			decode Decode) error
 		GetTopics() ([]string, error)
-		Publish(TopicName string, message interface{})
+		Publish(TopicName string, message interface{}) error
 		Rpc(PublishTopicName string,
 			PublishMessage interface{},
 			SubscribeTopicName string,
-			handle  func(message interface{}, Subject string),
+			handle func(message interface{}, Subject string),
 			decode Decode,
-			Opts *SubscriptionOptions)
+			Opts *SubscriptionOptions) error
 		Configure(ctx interface{})
 	}
 
@@ -28,6 +45,7 @@ type (
 		IsTlsEnabled    bool
 		Ca              []byte
 		ClientCert      []byte
+		ClientKey       []byte
 		IsConsumerGroup bool
 	}
 
@@ -37,5 +55,4 @@ type (
 	}
 )
 
-
-type Decode func(handleMsg func(message interface{},topicName string),msg []byte,topicName string)error
\ No newline at end of file
+type Decode func(handleMsg func(message interface{}, topicName string), msg []byte, topicName string) error
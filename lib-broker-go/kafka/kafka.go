@@ -1,41 +1,302 @@
 package kafka
 
 import (
-	"github.com/shopify/sarama"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	connector "github.com/nationaloilwellvarco/max-edge/lib-broker-go"
 )
 
 type (
 	Kafka struct {
-		ClientName    string
-		ClientUrls    []string
-		Consumer      sarama.Consumer
-		ConsumerGroup sarama.Consumer
-		IsTlsEnabled  bool
-		CaCert        []byte
-		ClientCert    []byte
-		ClientKey     []byte
+		sync.Mutex                                 //mutex guarding the clients and subscribers
+		sync.WaitGroup                             //waitgroup to track the subscriber goRoutines
+		ClientName      string                     //client id given to the broker
+		ClientUrls      []string                   //addresses of the brokers
+		Consumer        sarama.Consumer            //consumer of the subscriptions, nil while disconnected
+		ConsumerGroup   sarama.Consumer            //consumer of the consumer group
+		Producer        sarama.SyncProducer        //producer of the published messages, nil while disconnected
+		IsTlsEnabled    bool                       //whether or not the brokers are connected to with TLS
+		IsConsumerGroup bool                       //whether or not the subscriptions are part of a consumer group
+		CaCert          []byte                     //PEM CAs the brokers are verified against
+		ClientCert      []byte                     //PEM certificate of the client, optional
+		ClientKey       []byte                     //PEM key of the client certificate
+		subscribers     []sarama.PartitionConsumer //partition consumers of the subscriptions
 	}
 )
 
-func NewConnector(Options Connector.Options) connector.Connector {
-	return &kafka{
-		ClientName:      Options.ClientName,
-		ClientUrls:      Options.CleintUrls,
-		IsTlsEnabled:    Options.IsTlsEnabled,
-		CaCert:          Options.ca,
-		CleintCert:      options.cert,
-		IsConsumerGroup: options.IsConsumerGroup,
+//NewConnector creates a kafka connector from the options, it connects on Connect
+func NewConnector(options connector.Options) connector.Connector {
+	k := &Kafka{}
+	k.Configure(options)
+
+	return k
+}
+
+//Configure replaces the options of the connector, given as connector.Options, they're used on
+// the next Connect
+func (k *Kafka) Configure(ctx interface{}) {
+	var options connector.Options
+
+	switch o := ctx.(type) {
+	case connector.Options:
+		options = o
+	case *connector.Options:
+		if o == nil {
+			return
+		}
+		options = *o
+	default:
+		return
+	}
+	k.Lock()
+	defer k.Unlock()
+
+	k.ClientName = options.ClientName
+	k.ClientUrls = options.ServerUrls
+	k.IsTlsEnabled = options.IsTlsEnabled
+	k.CaCert = options.Ca
+	k.ClientCert = options.ClientCert
+	k.ClientKey = options.ClientKey
+	k.IsConsumerGroup = options.IsConsumerGroup
+}
+
+//config returns the configuration of the clients, must be called with the lock held
+func (k *Kafka) config() (config *sarama.Config, e error) {
+	config = sarama.NewConfig()
+	if k.ClientName != "" {
+		config.ClientID = k.ClientName
+	}
+	//the sync producer requires the successes to be returned
+	config.Producer.Return.Successes = true
+	if !k.IsTlsEnabled {
+		return
+	}
+	tlsConfig := &tls.Config{}
+	if len(k.CaCert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(k.CaCert) {
+			return nil, connector.ErrTLSConfig
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(k.ClientCert) > 0 {
+		certificate, err := tls.X509KeyPair(k.ClientCert, k.ClientKey)
+		if err != nil {
+			return nil, connector.ErrTLSConfig.Wrap(err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	config.Net.TLS.Enable, config.Net.TLS.Config = true, tlsConfig
+
+	return
+}
+
+//Connect connects the consumer and the producer to the brokers
+func (k *Kafka) Connect() (e error) {
+	k.Lock()
+	defer k.Unlock()
+
+	if k.Consumer != nil {
+		return connector.ErrAlreadyConnected
+	}
+	config, e := k.config()
+	if e != nil {
+		return
+	}
+	consumer, e := sarama.NewConsumer(k.ClientUrls, config)
+	if e != nil {
+		return connector.ErrNotConnected.Wrap(e)
+	}
+	producer, e := sarama.NewSyncProducer(k.ClientUrls, config)
+	if e != nil {
+		consumer.Close()
+
+		return connector.ErrNotConnected.Wrap(e)
+	}
+	k.Consumer, k.Producer = consumer, producer
+
+	return
+}
+
+//Disconnect stops the subscriptions and closes the consumer and the producer
+func (k *Kafka) Disconnect() (e error) {
+	k.Lock()
+	defer k.Unlock()
+
+	if k.Consumer == nil {
+		return connector.ErrNotConnected
+	}
+	k.stopSubscribers()
+	if err := k.Producer.Close(); err != nil {
+		e = connector.ErrDisconnect.Wrap(err)
+	}
+	if err := k.Consumer.Close(); err != nil {
+		e = connector.ErrDisconnect.Wrap(err)
+	}
+	k.Consumer, k.Producer = nil, nil
+
+	return
+}
+
+//stopSubscribers closes the partition consumers and waits for their goRoutines to return, must be
+// called with the lock held
+func (k *Kafka) stopSubscribers() {
+	for _, subscriber := range k.subscribers {
+		subscriber.AsyncClose()
 	}
+	k.Wait()
+	k.subscribers = nil
 }
 
-func (k *Kafka) Connect(Options Connector.Options) (e error) {
-	config := sarama.NewConfig()
-	config.Version = Options.ClientName
+//GetTopics returns the topics of the brokers
+func (k *Kafka) GetTopics() (topics []string, e error) {
+	k.Lock()
+	defer k.Unlock()
 
+	return k.topics()
 }
-func (k *Kafka) Disconnect(Options Connector.Options) (e error) {
-	if e := k.stopSubscriber(); e != nil {
+
+//topics returns the topics of the brokers, must be called with the lock held
+func (k *Kafka) topics() (topics []string, e error) {
+	if k.Consumer == nil {
+		return nil, connector.ErrNotConnected
+	}
+	if topics, e = k.Consumer.Topics(); e != nil {
+		e = connector.ErrTopicsUnavailable.Wrap(e)
+	}
+
+	return
+}
+
+//checkTopic returns an error unless the topic exists on the broker, must be called with the lock held
+func (k *Kafka) checkTopic(topicName string) (e error) {
+	if topicName == "" {
+		return connector.ErrTopicEmpty
+	}
+	topics, e := k.topics()
+	if e != nil {
 		return e
 	}
+	for _, topic := range topics {
+		if topic == topicName {
+			return nil
+		}
+	}
+
+	return connector.ErrTopicNotFoundf.Withf(topicName)
+}
+
+//Subscribe handles the messages of every partition of the topic, messages are decoded before they're
+// handled unless raw messages are requested, the messages published from now on are handled unless
+// the subscription starts at a time
+func (k *Kafka) Subscribe(topicName string, handle func(message interface{}, subject string), opts *connector.SubscriptionOptions, decode connector.Decode) (e error) {
+	var consumers []sarama.PartitionConsumer
+
+	k.Lock()
+	defer k.Unlock()
+
+	if e = k.checkTopic(topicName); e != nil {
+		return
+	}
+	if opts == nil {
+		opts = &connector.SubscriptionOptions{}
+	}
+	partitions, e := k.Consumer.Partitions(topicName)
+	if e != nil {
+		return connector.ErrSubscribef.Withf(topicName).Wrap(e)
+	}
+	offset := sarama.OffsetNewest
+	if !opts.StartAtTime.IsZero() {
+		offset = sarama.OffsetOldest
+	}
+	for _, partition := range partitions {
+		consumer, err := k.Consumer.ConsumePartition(topicName, partition, offset)
+		if err != nil {
+			for _, consumer := range consumers {
+				consumer.Close()
+			}
+
+			return connector.ErrSubscribef.Withf(topicName).Wrap(err)
+		}
+		consumers = append(consumers, consumer)
+	}
+	for _, consumer := range consumers {
+		k.Add(1)
+		go k.goSubscribe(consumer, handle, *opts, decode)
+	}
+	k.subscribers = append(k.subscribers, consumers...)
+
+	return
+}
+
+//goSubscribe - Creates a routine to handle the messages of a partition until it's closed
+func (k *Kafka) goSubscribe(consumer sarama.PartitionConsumer, handle func(message interface{}, subject string), opts connector.SubscriptionOptions, decode connector.Decode) {
+	defer k.Done()
+
+	for message := range consumer.Messages() {
+		if message.Timestamp.Before(opts.StartAtTime) {
+			continue
+		}
+		if opts.GetRaw || decode == nil {
+			handle(message.Value, message.Topic)
+			continue
+		}
+		//messages that can't be decoded aren't handled
+		decode(handle, message.Value, message.Topic)
+	}
+}
+
+//Publish publishes a message to the topic, bytes and strings are published as they are and other
+// messages as json
+func (k *Kafka) Publish(topicName string, message interface{}) (e error) {
+	k.Lock()
+	e = k.checkTopic(topicName)
+	producer := k.Producer
+	k.Unlock()
+
+	if e != nil {
+		return
+	}
+	value, e := encode(topicName, message)
+	if e != nil {
+		return
+	}
+	if _, _, err := producer.SendMessage(&sarama.ProducerMessage{Topic: topicName, Value: value}); err != nil {
+		e = connector.ErrPublishf.Withf(topicName).Wrap(err)
+	}
+
+	return
+}
+
+//encode returns the encoder of a message
+func encode(topicName string, message interface{}) (encoder sarama.Encoder, e error) {
+	switch message := message.(type) {
+	case sarama.Encoder:
+		encoder = message
+	case []byte:
+		encoder = sarama.ByteEncoder(message)
+	case string:
+		encoder = sarama.StringEncoder(message)
+	default:
+		bytes, err := json.Marshal(message)
+		if err != nil {
+			return nil, connector.ErrEncodef.Withf(topicName).Wrap(err)
+		}
+		encoder = sarama.ByteEncoder(bytes)
+	}
+
+	return
+}
+
+//Rpc subscribes to the topic of the replies before publishing the request so that no reply is missed
+func (k *Kafka) Rpc(PublishTopicName string, PublishMessage interface{}, SubscribeTopicName string, handle func(message interface{}, Subject string), decode connector.Decode, Opts *connector.SubscriptionOptions) (e error) {
+	if e = k.Subscribe(SubscribeTopicName, handle, Opts, decode); e != nil {
+		return
+	}
 
+	return k.Publish(PublishTopicName, PublishMessage)
 }
@@ -0,0 +1,143 @@
+package kafka
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	connector "github.com/nationaloilwellvarco/max-edge/lib-broker-go"
+)
+
+//fakeConsumer is a consumer of the topics of a broker, the methods not used by the connector are
+// those of the embedded nil consumer
+type fakeConsumer struct {
+	sarama.Consumer
+	topics    []string
+	topicsErr error
+	messages  chan *sarama.ConsumerMessage
+}
+
+func (c *fakeConsumer) Topics() ([]string, error) { return c.topics, c.topicsErr }
+
+func (c *fakeConsumer) Partitions(topic string) ([]int32, error) { return []int32{0}, nil }
+
+func (c *fakeConsumer) ConsumePartition(topic string, partition int32, offset int64) (sarama.PartitionConsumer, error) {
+	return &fakePartitionConsumer{messages: c.messages}, nil
+}
+
+func (c *fakeConsumer) Close() error { return nil }
+
+//fakePartitionConsumer hands out the messages of its channel until closed
+type fakePartitionConsumer struct {
+	sarama.PartitionConsumer
+	messages chan *sarama.ConsumerMessage
+	once     sync.Once
+}
+
+func (c *fakePartitionConsumer) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func (c *fakePartitionConsumer) AsyncClose() { c.once.Do(func() { close(c.messages) }) }
+
+func (c *fakePartitionConsumer) Close() error { c.AsyncClose(); return nil }
+
+//fakeProducer records the published messages
+type fakeProducer struct {
+	sarama.SyncProducer
+	sent []*sarama.ProducerMessage
+	err  error
+}
+
+func (p *fakeProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.sent = append(p.sent, msg)
+	return 0, 0, p.err
+}
+
+func (p *fakeProducer) Close() error { return nil }
+
+//connected returns a connector connected to fakes knowing the topic
+func connected() (*Kafka, *fakeConsumer, *fakeProducer) {
+	consumer := &fakeConsumer{topics: []string{"topic"}, messages: make(chan *sarama.ConsumerMessage, 1)}
+	producer := &fakeProducer{}
+
+	return &Kafka{Consumer: consumer, Producer: producer}, consumer, producer
+}
+
+func TestNotConnected(t *testing.T) {
+	k := NewConnector(connector.Options{ClientName: "test"})
+
+	if _, err := k.GetTopics(); !errors.Is(err, connector.ErrNotConnected) {
+		t.Errorf("topics returned %v", err)
+	}
+	if err := k.Publish("topic", "message"); !errors.Is(err, connector.ErrNotConnected) {
+		t.Errorf("publish returned %v", err)
+	}
+	if err := k.Disconnect(); !errors.Is(err, connector.ErrNotConnected) {
+		t.Errorf("disconnect returned %v", err)
+	}
+	k, _, _ = connected()
+	if err := k.Connect(); !errors.Is(err, connector.ErrAlreadyConnected) {
+		t.Errorf("connect returned %v", err)
+	}
+}
+
+func TestCheckTopic(t *testing.T) {
+	k, consumer, _ := connected()
+	handle := func(message interface{}, subject string) {}
+
+	if err := k.Subscribe("", handle, nil, nil); !errors.Is(err, connector.ErrTopicEmpty) {
+		t.Errorf("subscribe without topic returned %v", err)
+	}
+	if err := k.Subscribe("unknown", handle, nil, nil); !errors.Is(err, connector.ErrTopicNotFoundf) {
+		t.Errorf("subscribe to an unknown topic returned %v", err)
+	}
+	if err := k.Publish("unknown", "message"); !errors.Is(err, connector.ErrTopicNotFoundf) {
+		t.Errorf("publish to an unknown topic returned %v", err)
+	}
+	//the errors of the broker are wrapped
+	cause := errors.New("broker down")
+	consumer.topicsErr = cause
+	if _, err := k.GetTopics(); !errors.Is(err, connector.ErrTopicsUnavailable) || !errors.Is(err, cause) {
+		t.Errorf("topics returned %v", err)
+	}
+}
+
+func TestSubscribePublish(t *testing.T) {
+	k, consumer, producer := connected()
+	received := make(chan interface{}, 1)
+
+	err := k.Subscribe("topic", func(message interface{}, subject string) { received <- message }, &connector.SubscriptionOptions{GetRaw: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer.messages <- &sarama.ConsumerMessage{Topic: "topic", Value: []byte("raw"), Timestamp: time.Now()}
+	select {
+	case message := <-received:
+		if string(message.([]byte)) != "raw" {
+			t.Errorf("handled %v", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not handled")
+	}
+	if err = k.Publish("topic", map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := producer.sent[0].Value.Encode(); string(value) != `{"a":1}` {
+		t.Errorf("published %s", value)
+	}
+	cause := errors.New("broker down")
+	producer.err = cause
+	if err = k.Publish("topic", "message"); !errors.Is(err, connector.ErrPublishf) || !errors.Is(err, cause) {
+		t.Errorf("failed publish returned %v", err)
+	}
+	if err = k.Publish("topic", func() {}); !errors.Is(err, connector.ErrEncodef) {
+		t.Errorf("publish of a message that can't be encoded returned %v", err)
+	}
+	if err = k.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if k.Consumer != nil || len(k.subscribers) != 0 {
+		t.Error("subscriptions left after disconnect")
+	}
+}
@@ -0,0 +1,211 @@
+package catalog
+
+//---------------------------------------------------------------------------------------------------
+// catalog.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+//Category groups errors by their cause, the category decides the http status of an error
+type Category string
+
+//categories of errors
+const (
+	CategoryValidation   Category = "validation"   //the input is invalid
+	CategoryNotFound     Category = "notfound"     //something requested doesn't exist
+	CategoryConflict     Category = "conflict"     //the operation conflicts with the current state
+	CategoryUnauthorized Category = "unauthorized" //the caller isn't authenticated
+	CategoryForbidden    Category = "forbidden"    //the caller isn't allowed to do the operation
+	CategoryTimeout      Category = "timeout"      //the operation didn't complete in time
+	CategoryUnavailable  Category = "unavailable"  //a dependency isn't available
+	CategoryInternal     Category = "internal"     //unexpected failure
+	CategoryMethod       Category = "method"       //the method isn't allowed for the resource
+	CategoryTooLarge     Category = "toolarge"     //the input is larger than allowed
+	CategoryUnsupported  Category = "unsupported"  //the media type of the input isn't supported
+)
+
+//Severity tells how bad an error is
+type Severity string
+
+//severities of errors, the same strings as the levels of the logger
+const (
+	SeverityInfo  Severity = "Info"
+	SeverityWarn  Severity = "Warn"
+	SeverityError Severity = "Error"
+	SeverityFatal Severity = "Fatal"
+)
+
+//error constants
+const (
+	ErrCodeDuplicatef string = "error code \"%s\" registered twice"
+)
+
+//registry holds every error of the catalog by code
+var registry = struct {
+	sync.RWMutex
+	errors map[string]*Error
+}{
+	errors: make(map[string]*Error),
+}
+
+//Error is an error of the catalog, errors are matched by code so a formatted or wrapped copy of an
+// error still matches the error it was created from with errors.Is
+type Error struct {
+	Code     string   //unique code of the error, e.g. LOG-001
+	Category Category //cause of the error
+	Severity Severity //how bad the error is
+	Message  string   //message of the error, may be a format that's filled in by Withf
+	Err      error    //wrapped cause of the error, may be nil
+}
+
+//New creates an error and registers it in the catalog, it panics if the code is already registered
+// since codes are declared as package variables
+func New(code string, category Category, severity Severity, message string) *Error {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.errors[code]; ok {
+		panic(fmt.Sprintf(ErrCodeDuplicatef, code))
+	}
+	e := &Error{
+		Code:     code,
+		Category: category,
+		Severity: severity,
+		Message:  message,
+	}
+	registry.errors[code] = e
+
+	return e
+}
+
+//Lookup returns the registered error with the code
+func Lookup(code string) (e *Error, ok bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	e, ok = registry.errors[code]
+
+	return
+}
+
+//Errors returns every registered error sorted by code
+func Errors() (errs []*Error) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	for _, e := range registry.errors {
+		errs = append(errs, e)
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Code < errs[j].Code
+	})
+
+	return
+}
+
+//Error returns the message, followed by the wrapped error if any
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+//Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+//Is matches errors of the catalog by code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+
+	return ok && t.Code == e.Code
+}
+
+//Withf returns a copy of the error with its message formatted with the arguments
+func (e *Error) Withf(args ...interface{}) *Error {
+	copied := *e
+	copied.Message = fmt.Sprintf(e.Message, args...)
+
+	return &copied
+}
+
+//Wrap returns a copy of the error wrapping the cause
+func (e *Error) Wrap(err error) *Error {
+	copied := *e
+	copied.Err = err
+
+	return &copied
+}
+
+//HTTPStatus returns the http status matching the category
+func (c Category) HTTPStatus() int {
+	switch c {
+	case CategoryValidation:
+		return http.StatusBadRequest
+	case CategoryNotFound:
+		return http.StatusNotFound
+	case CategoryConflict:
+		return http.StatusConflict
+	case CategoryUnauthorized:
+		return http.StatusUnauthorized
+	case CategoryForbidden:
+		return http.StatusForbidden
+	case CategoryTimeout:
+		return http.StatusGatewayTimeout
+	case CategoryUnavailable:
+		return http.StatusServiceUnavailable
+	case CategoryMethod:
+		return http.StatusMethodNotAllowed
+	case CategoryTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case CategoryUnsupported:
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+//As returns the first error of the catalog in the chain of the error
+func As(err error) (e *Error, ok bool) {
+	ok = errors.As(err, &e)
+
+	return
+}
+
+//CodeOf returns the code of the first error of the catalog in the chain, empty if there is none
+func CodeOf(err error) string {
+	if e, ok := As(err); ok {
+		return e.Code
+	}
+
+	return ""
+}
+
+//HTTPStatus returns the http status of an error, errors that aren't part of the catalog are
+// internal server errors
+func HTTPStatus(err error) int {
+	if e, ok := As(err); ok {
+		return e.Category.HTTPStatus()
+	}
+
+	return http.StatusInternalServerError
+}
+
+//HTTPStatusOfCode returns the http status of a registered code, unknown codes are internal server
+// errors
+func HTTPStatusOfCode(code string) int {
+	if e, ok := Lookup(code); ok {
+		return e.Category.HTTPStatus()
+	}
+
+	return http.StatusInternalServerError
+}
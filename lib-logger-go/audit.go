@@ -0,0 +1,215 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// Keeps an audit trail of every change to the debug mode so that it can be proven when verbose
+// logging was enabled
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Audit sources
+const (
+	AuditSourceHTTP    string = "http"
+	AuditSourceBroker  string = "broker"
+	AuditSourceCode    string = "code"
+	AuditSourceTimer   string = "timer"
+	AuditSourceRestore string = "restore"
+)
+
+//AuditEvent describes a single change of the debug mode
+type AuditEvent struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Actor       string    `json:"actor"`             //who changed the debug mode
+	Source      string    `json:"source"`            //where the change came from
+	ServiceName string    `json:"service,omitempty"` //service changed, empty for the system debug mode
+	OldState    bool      `json:"oldState"`
+	NewState    bool      `json:"newState"`
+	Expiry      time.Time `json:"expiry"` //when debug mode will expire if enabled
+}
+
+//AuditFilter can be used to narrow down the audit history, empty fields match everything
+type AuditFilter struct {
+	ServiceName string
+	Source      string
+	Actor       string
+	Since       time.Time
+	Until       time.Time
+}
+
+//auditTrail is a ring buffer holding the most recent audit events
+type auditTrail struct {
+	sync.Mutex
+	events []AuditEvent
+	next   int
+	full   bool
+}
+
+//packagePrefix is used to skip the frames of this package when looking up the caller
+var packagePrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i+strings.Index(name[i:], ".")+1]
+	}
+
+	return name[:strings.Index(name, ".")+1]
+}()
+
+func newAuditTrail(size int) *auditTrail {
+	return &auditTrail{
+		events: make([]AuditEvent, size),
+	}
+}
+
+//resize changes the number of events kept, the most recent events are kept
+func (a *auditTrail) resize(size int) {
+	a.Lock()
+	defer a.Unlock()
+
+	ordered := a.ordered()
+	if len(ordered) > size {
+		ordered = ordered[len(ordered)-size:]
+	}
+	a.events = make([]AuditEvent, size)
+	a.next = copy(a.events, ordered)
+	a.full = size > 0 && a.next == size
+	if a.full {
+		a.next = 0
+	}
+}
+
+//add stores the event, overwriting the oldest event once the trail is full
+func (a *auditTrail) add(event AuditEvent) {
+	a.Lock()
+	defer a.Unlock()
+
+	if len(a.events) == 0 {
+		return
+	}
+	a.events[a.next] = event
+	a.next = (a.next + 1) % len(a.events)
+	if a.next == 0 {
+		a.full = true
+	}
+}
+
+//ordered returns the recorded events, oldest first, must be called with the lock held
+func (a *auditTrail) ordered() []AuditEvent {
+	if a.full {
+		return append(append([]AuditEvent{}, a.events[a.next:]...), a.events[:a.next]...)
+	}
+
+	return a.events[:a.next]
+}
+
+//query returns the events matching the filter, oldest first
+func (a *auditTrail) query(filter AuditFilter) (events []AuditEvent) {
+	a.Lock()
+	defer a.Unlock()
+
+	for _, event := range a.ordered() {
+		switch {
+		case filter.ServiceName != "" && filter.ServiceName != event.ServiceName,
+			filter.Source != "" && filter.Source != event.Source,
+			filter.Actor != "" && filter.Actor != event.Actor,
+			!filter.Since.IsZero() && event.Timestamp.Before(filter.Since),
+			!filter.Until.IsZero() && event.Timestamp.After(filter.Until):
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return
+}
+
+//callerIdentity returns the first function outside of this package in the call stack
+func callerIdentity() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePrefix) {
+			return frame.Function
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+//audit records a change of the debug mode and writes it to the log, the trail is never sampled or
+// deduplicated
+func (l *logger) audit(ctx context.Context, serviceName string, oldState, newState bool, expiry time.Time) {
+	event := AuditEvent{
+		Timestamp:   time.Now(),
+		Actor:       actorFromContext(ctx),
+		Source:      sourceFromContext(ctx),
+		ServiceName: serviceName,
+		OldState:    oldState,
+		NewState:    newState,
+		Expiry:      expiry,
+	}
+	if event.Actor == "" {
+		event.Actor = callerIdentity()
+	}
+	if event.Source == "" {
+		event.Source = AuditSourceCode
+	}
+	l.auditTrail.add(event)
+	bytes, _ := json.Marshal(event)
+	l.write(l.getCommonName(), string(bytes), INFO)
+}
+
+//GetAuditHistory returns the recorded changes of the debug mode matching the filter
+func (l *logger) GetAuditHistory(filter AuditFilter) []AuditEvent {
+	return l.auditTrail.query(filter)
+}
+
+//DebugMessageHandler returns a function handling the DebugJSON messages received from the broker,
+// e.g. subscribed to a topic, debug is enabled or disabled for the service or for everything if the
+// service name is empty, the changes are audited as coming from the broker with the subject as actor
+func DebugMessageHandler(l Logger, serviceName string) func(message interface{}, subject string) {
+	return func(message interface{}, subject string) {
+		var serviceNames []string
+
+		debugJSON, err := decodeDebugMessage(message)
+		if err != nil {
+			l.FormatError(InfoErrUpdateDebug, err)
+			return
+		}
+		if serviceName != "" {
+			serviceNames = append(serviceNames, serviceName)
+		}
+		ctx := WithActor(WithSource(context.Background(), AuditSourceBroker), subject)
+		if debugJSON.DebugEnabled {
+			l.EnableDebugContext(ctx, serviceNames...)
+		} else {
+			l.DisableDebugContext(ctx, serviceNames...)
+		}
+	}
+}
+
+//decodeDebugMessage reads a DebugJSON message either decoded or raw
+func decodeDebugMessage(message interface{}) (debugJSON DebugJSON, err error) {
+	switch message := message.(type) {
+	case DebugJSON:
+		debugJSON = message
+	case *DebugJSON:
+		debugJSON = *message
+	case []byte:
+		err = json.Unmarshal(message, &debugJSON)
+	case json.RawMessage:
+		err = json.Unmarshal(message, &debugJSON)
+	default:
+		err = ErrCastRawMessage
+	}
+
+	return
+}
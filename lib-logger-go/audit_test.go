@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+//auditServices returns the services of the events
+func auditServices(events []AuditEvent) (serviceNames []string) {
+	for _, event := range events {
+		serviceNames = append(serviceNames, event.ServiceName)
+	}
+
+	return
+}
+
+func TestAuditTrail(t *testing.T) {
+	trail := newAuditTrail(3)
+	for _, serviceName := range []string{"a", "b", "c", "d"} {
+		trail.add(AuditEvent{ServiceName: serviceName})
+	}
+	if services := strings.Join(auditServices(trail.query(AuditFilter{})), ""); services != "bcd" {
+		t.Errorf("full trail holds %s", services)
+	}
+	//resizing keeps the most recent events
+	trail.resize(5)
+	trail.add(AuditEvent{ServiceName: "e"})
+	if services := strings.Join(auditServices(trail.query(AuditFilter{})), ""); services != "bcde" {
+		t.Errorf("grown trail holds %s", services)
+	}
+	trail.resize(2)
+	if services := strings.Join(auditServices(trail.query(AuditFilter{})), ""); services != "de" {
+		t.Errorf("shrunk trail holds %s", services)
+	}
+	trail.add(AuditEvent{ServiceName: "f"})
+	if services := strings.Join(auditServices(trail.query(AuditFilter{})), ""); services != "ef" {
+		t.Errorf("shrunk trail holds %s after an event", services)
+	}
+	trail.resize(0)
+	trail.add(AuditEvent{ServiceName: "g"})
+	if events := trail.query(AuditFilter{}); len(events) != 0 {
+		t.Errorf("empty trail holds %v", events)
+	}
+}
+
+func TestGetAuditHistory(t *testing.T) {
+	inTempDir(t)
+
+	l := newTestLogger(t, nil, "a", "b")
+	start := time.Now()
+	l.EnableDebugContext(WithActor(WithSource(context.Background(), AuditSourceHTTP), "alice"), "a")
+	l.EnableDebugContext(WithActor(WithSource(context.Background(), AuditSourceBroker), "bob"), "b")
+	l.DisableDebugContext(WithActor(context.Background(), "alice"), "a")
+	end := time.Now()
+
+	for name, test := range map[string]struct {
+		filter   AuditFilter
+		services string
+	}{
+		"everything": {AuditFilter{}, "aba"},
+		"service":    {AuditFilter{ServiceName: "a"}, "aa"},
+		"source":     {AuditFilter{Source: AuditSourceBroker}, "b"},
+		"actor":      {AuditFilter{Actor: "alice"}, "aa"},
+		"code":       {AuditFilter{Source: AuditSourceCode, Actor: "alice"}, "a"},
+		"since":      {AuditFilter{Since: end.Add(time.Second)}, ""},
+		"until":      {AuditFilter{Until: start.Add(-time.Second)}, ""},
+		"period":     {AuditFilter{Since: start, Until: end}, "aba"},
+	} {
+		if services := strings.Join(auditServices(l.GetAuditHistory(test.filter)), ""); services != test.services {
+			t.Errorf("%s filter returned the events of %q", name, services)
+		}
+	}
+	events := l.GetAuditHistory(AuditFilter{})
+	if event := events[2]; event.OldState != true || event.NewState != false {
+		t.Errorf("disable audited as %+v", event)
+	}
+}
+
+func TestAuditActor(t *testing.T) {
+	inTempDir(t)
+
+	l := newTestLogger(t, nil, "service")
+	l.EnableDebugContext(WithActor(context.Background(), "alice"), "service")
+	//without actor the caller outside of the logger is audited
+	l.DisableDebug("service")
+
+	events := l.GetAuditHistory(AuditFilter{})
+	if len(events) != 2 {
+		t.Fatalf("audited %v", events)
+	}
+	if events[0].Actor != "alice" || events[0].Source != AuditSourceCode {
+		t.Errorf("change with an actor audited as %+v", events[0])
+	}
+	if events[1].Actor == "" || strings.HasPrefix(events[1].Actor, packagePrefix) {
+		t.Errorf("change without actor audited as %+v", events[1])
+	}
+}
+
+func TestAuditNotSampled(t *testing.T) {
+	inTempDir(t)
+
+	l := newTestLogger(t, nil, "service")
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	l.ConfigureSampling(SamplingRule{Tick: time.Hour})
+	for i := 0; i < 3; i++ {
+		l.EnableDebug("service")
+		l.DisableDebug("service")
+	}
+	if err := l.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile("test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := strings.Count(string(content), "newState"); count != 6 {
+		t.Errorf("%d audit entries written out of 6", count)
+	}
+	if strings.Contains(string(content), "Debug Mode Enabled") {
+		t.Error("sampled entry written")
+	}
+}
+
+func TestDebugMessageHandler(t *testing.T) {
+	inTempDir(t)
+
+	l := newTestLogger(t, nil, "service")
+	handle := DebugMessageHandler(l, "service")
+
+	handle(DebugJSON{DebugEnabled: true}, "debug.service")
+	if !l.IsDebugEnabled("service") {
+		t.Error("debug not enabled by the message")
+	}
+	raw, _ := json.Marshal(DebugJSON{DebugEnabled: false})
+	handle(raw, "debug.service")
+	if l.IsDebugEnabled("service") {
+		t.Error("debug not disabled by the raw message")
+	}
+	handle("invalid", "debug.service")
+	handle([]byte("{"), "debug.service")
+
+	events := l.GetAuditHistory(AuditFilter{})
+	if len(events) != 2 {
+		t.Fatalf("audited %v", events)
+	}
+	for _, event := range events {
+		if event.Source != AuditSourceBroker || event.Actor != "debug.service" || event.ServiceName != "service" {
+			t.Errorf("message audited as %+v", event)
+		}
+	}
+	//without service name the system debug mode is changed
+	DebugMessageHandler(l, "")(&DebugJSON{DebugEnabled: true}, "debug.all")
+	if !l.GetSystemDebugStatus() {
+		t.Error("system debug not enabled by the message")
+	}
+}
@@ -0,0 +1,391 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// Hash chains the entries written to the log files so that deleted, reordered or modified entries
+// can be detected. Each file starts with a header linking it to the previous file and is closed
+// with a footer signed with an ed25519 key when it's rotated. A file continued after a restart gets
+// a segment per start, each with its own header and footer.
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+//chain constants
+const (
+	chainField     string = `,"chain":"`
+	chainFieldSize int    = len(chainField) + sha256.Size*2 + 1 //size the chain field adds to an entry
+)
+
+//ChainHeader is the first entry of a chained file or segment
+type ChainHeader struct {
+	Previous string    `json:"prev"`    //hash of the last entry of the previous file
+	Started  time.Time `json:"started"` //when the file was started
+}
+
+//ChainFooter is the last entry of a chained file or segment, written when the file is rotated or closed
+type ChainFooter struct {
+	Entries   int    `json:"entries"`       //number of entries in the segment, excluding header and footer
+	Last      string `json:"last"`          //hash of the last entry
+	Signature string `json:"sig,omitempty"` //ed25519 signature of the footer
+}
+
+//ChainResult describes a verified chained file
+type ChainResult struct {
+	Previous string //hash of the last entry of the previous file as stated by the header
+	Last     string //hash of the last entry of the file
+	Entries  int    //number of entries in the file, excluding headers and footers
+	Sealed   bool   //whether or not the file ends with a footer
+	Signed   bool   //whether or not the footer signatures were verified
+}
+
+type chainHeaderEntry struct {
+	Header ChainHeader `json:"chainHeader"`
+}
+
+type chainFooterEntry struct {
+	Footer *ChainFooter `json:"chainFooter"`
+}
+
+//rawEntry wraps an entry that isn't a json object so that it can carry the chain field
+type rawEntry struct {
+	Raw string `json:"raw"`
+}
+
+//hashChain keeps the state of the chain of the file currently written
+type hashChain struct {
+	previous [sha256.Size]byte
+	entries  int
+	key      ed25519.PrivateKey
+}
+
+func newHashChain(key ed25519.PrivateKey) *hashChain {
+	return &hashChain{
+		key: key,
+	}
+}
+
+//link returns the hash of an entry chained to the previous hash
+func link(previous []byte, entry []byte) [sha256.Size]byte {
+	hash := sha256.New()
+	hash.Write(previous)
+	hash.Write(entry)
+
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+
+	return sum
+}
+
+//footerMessage returns the bytes that are signed by the footer
+func footerMessage(entries int, last string) []byte {
+	return []byte(fmt.Sprintf("%d:%s", entries, last))
+}
+
+//seal adds the chain field to a json entry and advances the chain
+func (c *hashChain) seal(entry []byte) []byte {
+	entry = bytes.TrimRight(entry, "\n")
+	//only json objects can carry the chain field
+	if len(entry) == 0 || entry[len(entry)-1] != '}' {
+		entry, _ = json.Marshal(rawEntry{Raw: string(entry)})
+	}
+	c.previous = link(c.previous[:], entry)
+	sealed := make([]byte, 0, len(entry)+chainFieldSize+1)
+	sealed = append(sealed, entry[:len(entry)-1]...)
+	sealed = append(sealed, chainField...)
+	sealed = append(sealed, hex.EncodeToString(c.previous[:])...)
+	sealed = append(sealed, "\"}\n"...)
+
+	return sealed
+}
+
+//header starts the chain of a new file
+func (c *hashChain) header() []byte {
+	entry, _ := json.Marshal(chainHeaderEntry{
+		Header: ChainHeader{
+			Previous: hex.EncodeToString(c.previous[:]),
+			Started:  time.Now(),
+		},
+	})
+	c.entries = 0
+
+	return c.seal(entry)
+}
+
+//footer closes the chain of the current file
+func (c *hashChain) footer() []byte {
+	footer := ChainFooter{
+		Entries: c.entries,
+		Last:    hex.EncodeToString(c.previous[:]),
+	}
+	if c.key != nil {
+		footer.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(c.key, footerMessage(footer.Entries, footer.Last)))
+	}
+	entry, _ := json.Marshal(chainFooterEntry{Footer: &footer})
+
+	return append(entry, '\n')
+}
+
+//sealEntry seals an entry written by the logger
+func (c *hashChain) sealEntry(entry []byte) []byte {
+	c.entries++
+
+	return c.seal(entry)
+}
+
+//resume continues the chain of the last file written to the log before a restart so that the next
+// entries are chained to it, the file is sealed if it was left without a footer, e.g. by a crash,
+// unless it's continued, the chain starts over if the file can't be verified
+func (c *hashChain) resume(logName string, keys []EncryptionKey, seal bool) (result ChainResult, err error) {
+	files, err := LogFiles(logName)
+	if err != nil {
+		return
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if info, statErr := os.Stat(files[i]); statErr == nil && info.Size() > 0 {
+			return c.resumeFile(files[i], keys, seal)
+		}
+	}
+
+	return
+}
+
+//resumeFile continues the chain of a file
+func (c *hashChain) resumeFile(path string, keys []EncryptionKey, seal bool) (result ChainResult, err error) {
+	reader, err := OpenPlainLogFile(path, keys)
+	if err != nil {
+		return
+	}
+	result, entries, err := verifyChain(reader, nil)
+	reader.Close()
+	if err != nil {
+		return
+	}
+	previous, err := hex.DecodeString(result.Last)
+	if err != nil {
+		return
+	}
+	copy(c.previous[:], previous)
+	c.entries = entries
+	//compressed backups can't be appended to
+	if !seal || result.Sealed || strings.HasSuffix(path, ".gz") {
+		return
+	}
+	if err = appendEntry(path, c.footer(), keys); err == nil {
+		result.Sealed = true
+	}
+
+	return
+}
+
+//appendEntry appends an entry to a file, encrypted with the key of the file if it's encrypted
+func appendEntry(path string, entry []byte, keys []EncryptionKey) (err error) {
+	var header EncryptionHeader
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	firstLine, _ := reader.ReadBytes('\n')
+	if IsEncryptedLog(bytes.TrimSpace(firstLine)) {
+		//the entry is encrypted with the line it's written at
+		lines := int64(1)
+		for {
+			if _, readErr := reader.ReadBytes('\n'); readErr != nil {
+				break
+			}
+			lines++
+		}
+		json.Unmarshal(bytes.TrimSpace(firstLine), &header)
+		err = ErrEncryptionKeyIDf.Withf(header.KeyID)
+		for _, key := range keys {
+			if key.ID == header.KeyID {
+				var cipher *entryCipher
+
+				if cipher, err = newEntryCipher(key); err == nil {
+					entry, err = cipher.encrypt(entry, lines+1)
+				}
+				break
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+	_, err = file.Write(entry)
+
+	return
+}
+
+//unseal splits a sealed entry into the original entry and its hash
+func unseal(line []byte) (entry []byte, hash []byte, err error) {
+	i := bytes.LastIndex(line, []byte(chainField))
+	if i < 0 || len(line) != i+chainFieldSize+1 || !bytes.HasSuffix(line, []byte("\"}")) {
+		err = ErrChainEntryMalformed
+
+		return
+	}
+	if hash, err = hex.DecodeString(string(line[i+len(chainField) : len(line)-2])); err != nil {
+		return
+	}
+	entry = append(append(entry, line[:i]...), '}')
+
+	return
+}
+
+//VerifyChain reads a chained file and verifies that no entry was deleted, reordered or modified,
+// the footer signatures are verified if a public key is provided, a file continued after a restart
+// has a segment per start, each starting with a header chained to the footer of the previous one
+func VerifyChain(reader io.Reader, publicKey ed25519.PublicKey) (result ChainResult, err error) {
+	result, _, err = verifyChain(reader, publicKey)
+
+	return
+}
+
+//verifyChain verifies a chained file, the number of entries of its last segment is returned as well
+func verifyChain(reader io.Reader, publicKey ed25519.PublicKey) (result ChainResult, entries int, err error) {
+	var previous []byte
+	var footer *ChainFooter
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Bytes()
+		//the footer isn't chained
+		var footerEntry chainFooterEntry
+		if json.Unmarshal(line, &footerEntry) == nil && footerEntry.Footer != nil {
+			if footer != nil || lineNumber == 1 {
+				err = ErrChainAfterFooterf.Withf(lineNumber)
+
+				return
+			}
+			footer = footerEntry.Footer
+			if err = checkFooter(footer, entries, previous, publicKey); err != nil {
+				return
+			}
+			continue
+		}
+		entry, hash, unsealErr := unseal(line)
+		if unsealErr != nil {
+			err = ErrChainBrokenf.Withf(lineNumber).Wrap(unsealErr)
+
+			return
+		}
+		//the first entry of each segment is the header which holds the hash it's chained to
+		if lineNumber == 1 || footer != nil {
+			var headerEntry chainHeaderEntry
+			var headerPrevious []byte
+
+			if err = json.Unmarshal(entry, &headerEntry); err != nil {
+				err = ErrChainBrokenf.Withf(lineNumber).Wrap(err)
+
+				return
+			}
+			if headerPrevious, err = hex.DecodeString(headerEntry.Header.Previous); err != nil {
+				err = ErrChainBrokenf.Withf(lineNumber).Wrap(err)
+
+				return
+			}
+			if lineNumber == 1 {
+				result.Previous = headerEntry.Header.Previous
+			} else if !bytes.Equal(headerPrevious, previous) {
+				err = ErrChainBrokenf.Withf(lineNumber).Wrap(ErrChainHashMismatch)
+
+				return
+			}
+			previous, footer, entries = headerPrevious, nil, 0
+		} else {
+			result.Entries++
+			entries++
+		}
+		if sum := link(previous, entry); !bytes.Equal(sum[:], hash) {
+			err = ErrChainBrokenf.Withf(lineNumber).Wrap(ErrChainHashMismatch)
+
+			return
+		}
+		previous = hash
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	result.Last = hex.EncodeToString(previous)
+	result.Sealed = footer != nil
+	result.Signed = result.Sealed && publicKey != nil
+
+	return
+}
+
+//checkFooter checks that a footer matches the entries of its segment and its signature if a public
+// key is provided
+func checkFooter(footer *ChainFooter, entries int, last []byte, publicKey ed25519.PublicKey) (err error) {
+	if footer.Entries != entries || footer.Last != hex.EncodeToString(last) {
+		return ErrChainFooterMismatch
+	}
+	if publicKey != nil {
+		signature, decodeErr := base64.StdEncoding.DecodeString(footer.Signature)
+		if decodeErr != nil || !ed25519.Verify(publicKey, footerMessage(footer.Entries, footer.Last), signature) {
+			return ErrChainSignature
+		}
+	}
+
+	return
+}
+
+//LoadSigningKey reads an ed25519 private key from a PKCS #8 PEM file
+func LoadSigningKey(path string) (key ed25519.PrivateKey, err error) {
+	parsed, err := parsePEM(path, func(der []byte) (interface{}, error) {
+		return x509.ParsePKCS8PrivateKey(der)
+	})
+	if err != nil {
+		return
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		err = ErrChainKeyType
+	}
+
+	return
+}
+
+//LoadVerifyKey reads an ed25519 public key from a PKIX PEM file
+func LoadVerifyKey(path string) (key ed25519.PublicKey, err error) {
+	parsed, err := parsePEM(path, x509.ParsePKIXPublicKey)
+	if err != nil {
+		return
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		err = ErrChainKeyType
+	}
+
+	return
+}
+
+func parsePEM(path string, parse func(der []byte) (interface{}, error)) (interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, ErrChainKeyType
+	}
+
+	return parse(block.Bytes)
+}
@@ -0,0 +1,296 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+)
+
+//newSigningKey returns a new ed25519 key
+func newSigningKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+//newTestEncryptionKey returns a new AES key with the id
+func newTestEncryptionKey(t *testing.T, id string) EncryptionKey {
+	t.Helper()
+
+	key := EncryptionKey{ID: id, Key: make([]byte, 32)}
+	if _, err := rand.Read(key.Key); err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+//openTestSink opens a chained sink of the file, encrypted with the first key if any
+func openTestSink(t *testing.T, path string, key ed25519.PrivateKey, keys ...EncryptionKey) *fileSink {
+	t.Helper()
+
+	var cipher *entryCipher
+	if len(keys) > 0 {
+		var err error
+		if cipher, err = newEntryCipher(keys[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return newFileSink(&lumberjack.Logger{Filename: path, MaxSize: 1}, newHashChain(key), cipher, keys)
+}
+
+//writeEntries writes json entries to a sink
+func writeEntries(t *testing.T, sink *fileSink, count int) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		if _, err := sink.Write([]byte(`{"level":"Info","content":"entry"}` + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+//verifyFile verifies the chain of a file, decrypting it with the keys
+func verifyFile(t *testing.T, path string, publicKey ed25519.PublicKey, keys ...EncryptionKey) (ChainResult, error) {
+	t.Helper()
+
+	reader, err := OpenPlainLogFile(path, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	return VerifyChain(reader, publicKey)
+}
+
+//readLines returns the lines of a file
+func readLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.SplitAfter(bytes.TrimSuffix(content, []byte("\n")), []byte("\n"))
+}
+
+//writeLines replaces the lines of a file
+func writeLines(t *testing.T, path string, lines [][]byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, bytes.Join(lines, nil), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//chainedFile writes a sealed file of three entries and returns its path
+func chainedFile(t *testing.T, key ed25519.PrivateKey) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.log")
+	sink := openTestSink(t, path, key)
+	writeEntries(t, sink, 3)
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestVerifyChain(t *testing.T) {
+	key := newSigningKey(t)
+	path := chainedFile(t, key)
+
+	result, err := verifyFile(t, path, key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Entries != 3 || !result.Sealed || !result.Signed {
+		t.Errorf("verified as %+v", result)
+	}
+}
+
+func TestVerifyChainTampered(t *testing.T) {
+	key := newSigningKey(t)
+	publicKey := key.Public().(ed25519.PublicKey)
+
+	for name, tamper := range map[string]func(lines [][]byte) [][]byte{
+		"modified": func(lines [][]byte) [][]byte {
+			lines[2] = bytes.Replace(lines[2], []byte("entry"), []byte("entrY"), 1)
+			return lines
+		},
+		"deleted": func(lines [][]byte) [][]byte {
+			return append(lines[:2:2], lines[3:]...)
+		},
+		"reordered": func(lines [][]byte) [][]byte {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		},
+		"truncated mid line": func(lines [][]byte) [][]byte {
+			lines = lines[:3]
+			lines[2] = lines[2][:len(lines[2])/2]
+			return lines
+		},
+	} {
+		path := chainedFile(t, key)
+		writeLines(t, path, tamper(readLines(t, path)))
+		if _, err := verifyFile(t, path, publicKey); !errors.Is(err, ErrChainBrokenf) {
+			t.Errorf("%s file verified with %v", name, err)
+		}
+	}
+	//a file truncated after an entry loses its footer
+	path := chainedFile(t, key)
+	writeLines(t, path, readLines(t, path)[:3])
+	if result, err := verifyFile(t, path, publicKey); err != nil || result.Sealed {
+		t.Errorf("truncated file verified as %+v, %v", result, err)
+	}
+	//the footer must match the entries of the file
+	path = chainedFile(t, key)
+	lines := readLines(t, path)
+	lines[len(lines)-1] = bytes.Replace(lines[len(lines)-1], []byte(`"entries":3`), []byte(`"entries":2`), 1)
+	writeLines(t, path, lines)
+	if _, err := verifyFile(t, path, publicKey); !errors.Is(err, ErrChainFooterMismatch) {
+		t.Errorf("file with a forged footer verified with %v", err)
+	}
+}
+
+func TestVerifyChainFooterKey(t *testing.T) {
+	key, other := newSigningKey(t), newSigningKey(t)
+	path := chainedFile(t, key)
+
+	if _, err := verifyFile(t, path, other.Public().(ed25519.PublicKey)); !errors.Is(err, ErrChainSignature) {
+		t.Errorf("footer verified with another key with %v", err)
+	}
+	//a footer signed again with another key isn't valid
+	lines := readLines(t, path)
+	var footerEntry chainFooterEntry
+	if err := json.Unmarshal(lines[len(lines)-1], &footerEntry); err != nil || footerEntry.Footer == nil {
+		t.Fatalf("last line isn't a footer: %s", lines[len(lines)-1])
+	}
+	footer := footerEntry.Footer
+	footer.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(other, footerMessage(footer.Entries, footer.Last)))
+	line, _ := json.Marshal(footerEntry)
+	lines[len(lines)-1] = append(line, '\n')
+	writeLines(t, path, lines)
+	if _, err := verifyFile(t, path, key.Public().(ed25519.PublicKey)); !errors.Is(err, ErrChainSignature) {
+		t.Errorf("footer signed with another key verified with %v", err)
+	}
+	if _, err := verifyFile(t, path, other.Public().(ed25519.PublicKey)); err != nil {
+		t.Errorf("footer signed again not verified with its key: %v", err)
+	}
+}
+
+func TestChainRestart(t *testing.T) {
+	key := newSigningKey(t)
+	publicKey := key.Public().(ed25519.PublicKey)
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	//the file is continued with a new segment on every restart rather than rotated
+	for i := 0; i < 3; i++ {
+		sink := openTestSink(t, path, key)
+		writeEntries(t, sink, 2)
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//a crash leaves the file unsealed, it's continued in the same segment
+	sink := openTestSink(t, path, key)
+	writeEntries(t, sink, 2)
+	openSinksMu.Lock()
+	delete(openSinks, sink.path)
+	openSinksMu.Unlock()
+	sink = openTestSink(t, path, key)
+	writeEntries(t, sink, 1)
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if files, err := LogFiles(path); err != nil || len(files) != 1 {
+		t.Errorf("restarts rotated the file into %v, %v", files, err)
+	}
+	result, err := verifyFile(t, path, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Entries != 9 || !result.Sealed || !result.Signed {
+		t.Errorf("restarted file verified as %+v", result)
+	}
+	//an entry removed from an earlier segment is detected
+	lines := readLines(t, path)
+	writeLines(t, path, append(lines[:1:1], lines[2:]...))
+	if _, err = verifyFile(t, path, publicKey); err == nil {
+		t.Error("restarted file with a deleted entry verified")
+	}
+}
+
+func TestChainRestartEncrypted(t *testing.T) {
+	key := newSigningKey(t)
+	publicKey := key.Public().(ed25519.PublicKey)
+	first, second := newTestEncryptionKey(t, "first"), newTestEncryptionKey(t, "second")
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	sink := openTestSink(t, path, key, first)
+	writeEntries(t, sink, 2)
+	sink.Close()
+	//the same key continues the file
+	sink = openTestSink(t, path, key, first)
+	writeEntries(t, sink, 2)
+	sink.Close()
+	if files, _ := LogFiles(path); len(files) != 1 {
+		t.Fatalf("restart with the same key rotated the file into %v", files)
+	}
+	//another key rotates the file, the new file is chained to the previous one
+	sink = openTestSink(t, path, key, second, first)
+	writeEntries(t, sink, 1)
+	sink.Close()
+	files, err := LogFiles(path)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("restart with another key left the files %v, %v", files, err)
+	}
+	backup, err := verifyFile(t, files[0], publicKey, first)
+	if err != nil || backup.Entries != 4 || !backup.Sealed {
+		t.Errorf("backup verified as %+v, %v", backup, err)
+	}
+	current, err := verifyFile(t, files[1], publicKey, second)
+	if err != nil || current.Entries != 1 || current.Previous != backup.Last {
+		t.Errorf("file verified as %+v, %v, chained to %s", current, err, backup.Last)
+	}
+}
+
+func TestChainRawEntries(t *testing.T) {
+	key := newSigningKey(t)
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	sink := openTestSink(t, path, key)
+	if _, err := sink.Write([]byte("plain text\n")); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+	lines := readLines(t, path)
+	entry, _, err := unseal(bytes.TrimSuffix(lines[1], []byte("\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw rawEntry
+	if err = json.Unmarshal(entry, &raw); err != nil || raw.Raw != "plain text" {
+		t.Errorf("raw entry written as %s", entry)
+	}
+	if _, err = verifyFile(t, path, key.Public().(ed25519.PublicKey)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+//---------------------------------------------------------------------------------------------------
+// logdecrypt streams the plaintext of encrypted log files, the key of each file is looked up by the
+// id stored in its header
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	logger "github.com/nationaloilwellvarco/max-edge/lib-logger-go"
+)
+
+func main() {
+	var keys []logger.EncryptionKey
+
+	keyFile := flag.String("keys", "", "file holding keys of the form <id>:<base64 key>, one per line")
+	all := flag.Bool("all", false, "decrypt the backups of each log file as well, oldest first")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-keys keys.txt] [-all] <log file>...\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "a single key can also be provided with %s, as it is to the logger\n", logger.EnvNameLogEncryptionKey)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *keyFile != "" {
+		var err error
+		if keys, err = logger.LoadEncryptionKeys(*keyFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if value := os.Getenv(logger.EnvNameLogEncryptionKey); value != "" {
+		key, err := logger.ParseEncryptionKey(value)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		keys = append(keys, key)
+	}
+	var files []string
+	for _, arg := range flag.Args() {
+		if !*all {
+			files = append(files, arg)
+			continue
+		}
+		backups, err := logger.LogFiles(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		files = append(files, backups...)
+	}
+	for _, file := range files {
+		if err := decrypt(file, keys); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			os.Exit(1)
+		}
+	}
+}
+
+//decrypt writes the plaintext of a single file to stdout
+func decrypt(file string, keys []logger.EncryptionKey) (err error) {
+	reader, err := logger.OpenLogFile(file)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	return logger.DecryptLog(reader, os.Stdout, keys)
+}
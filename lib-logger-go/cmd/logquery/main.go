@@ -0,0 +1,218 @@
+package main
+
+//---------------------------------------------------------------------------------------------------
+// logquery reads a log file and its backups, including compressed and encrypted ones, and prints
+// the entries matching the given filters
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	logger "github.com/nationaloilwellvarco/max-edge/lib-logger-go"
+)
+
+//output formats
+const (
+	OutputJSON string = "json"
+	OutputText string = "text"
+)
+
+//followInterval is how often the log file is polled for new entries in follow mode
+const followInterval = 500 * time.Millisecond
+
+func main() {
+	var query logger.Query
+	var keys []logger.EncryptionKey
+
+	services := flag.String("service", "", "comma separated service names to match")
+	level := flag.String("level", "", "minimum level to match (debug, info, warn, error, fatal)")
+	since := flag.String("since", "", "match entries at or after this time (RFC3339) or duration ago (e.g. 1h)")
+	until := flag.String("until", "", "match entries before this time (RFC3339) or duration ago")
+	pattern := flag.String("regex", "", "match entries whose content matches the regular expression")
+	output := flag.String("output", OutputText, "output format, json or text")
+	follow := flag.Bool("follow", false, "keep printing new entries as they are written")
+	keyFile := flag.String("keys", "", "file holding the keys of encrypted log files")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <log file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || (*output != OutputJSON && *output != OutputText) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	//build the query from the flags
+	if *services != "" {
+		query.Services = strings.Split(*services, ",")
+	}
+	switch strings.ToLower(*level) {
+	case "", "debug", "info", "warn", "error", "fatal":
+		query.Level = *level
+	default:
+		exit(fmt.Errorf("unknown level %q", *level))
+	}
+	var err error
+	if query.Since, err = parseTime(*since); err != nil {
+		exit(err)
+	}
+	if query.Until, err = parseTime(*until); err != nil {
+		exit(err)
+	}
+	if *pattern != "" {
+		if query.Pattern, err = regexp.Compile(*pattern); err != nil {
+			exit(err)
+		}
+	}
+	if *keyFile != "" {
+		if keys, err = logger.LoadEncryptionKeys(*keyFile); err != nil {
+			exit(err)
+		}
+	}
+	//read the backups, oldest first, then the log file itself
+	files, err := logger.LogFiles(flag.Arg(0))
+	if err != nil {
+		exit(err)
+	}
+	writer := bufio.NewWriter(os.Stdout)
+	defer writer.Flush()
+	emit := func(line []byte) {
+		record, err := logger.ParseRecord(line)
+		if err != nil || !query.Match(record) {
+			return
+		}
+		if *output == OutputJSON {
+			writer.Write(record.Raw)
+			writer.WriteByte('\n')
+		} else {
+			fmt.Fprintf(writer, "%s %-5s [%s] %s\n", record.Timestamp.Format(time.RFC3339Nano),
+				strings.ToUpper(record.Level), record.Name, record.Content)
+		}
+	}
+	for _, file := range files {
+		if *follow && file == flag.Arg(0) {
+			break
+		}
+		if err = read(file, keys, emit); err != nil {
+			exit(fmt.Errorf("%s: %s", file, err))
+		}
+	}
+	writer.Flush()
+	if *follow {
+		if err = tail(flag.Arg(0), keys, emit, writer); err != nil {
+			exit(err)
+		}
+	}
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
+
+//parseTime parses an RFC3339 time or a duration before now
+func parseTime(value string) (t time.Time, err error) {
+	if value == "" {
+		return
+	}
+	if duration, durationErr := time.ParseDuration(value); durationErr == nil {
+		return time.Now().Add(-duration), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+//read passes every line of a file to the emit function
+func read(file string, keys []logger.EncryptionKey, emit func(line []byte)) (err error) {
+	reader, err := logger.OpenPlainLogFile(file, keys)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		emit(scanner.Bytes())
+	}
+
+	return scanner.Err()
+}
+
+//tail passes every line of the log file to the emit function and keeps polling for new lines,
+// re-opening the file when it's rotated, the lines of an encrypted file are decrypted
+func tail(logName string, keys []logger.EncryptionKey, emit func(line []byte), writer *bufio.Writer) (err error) {
+	var file *os.File
+	var pending []byte
+	var decrypter *logger.LineDecrypter
+
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+	buffer := make([]byte, 64*1024)
+	for {
+		if file == nil {
+			if file, err = os.Open(logName); err != nil {
+				if !os.IsNotExist(err) {
+					return
+				}
+				file = nil
+				time.Sleep(followInterval)
+				continue
+			}
+			pending = nil
+			decrypter = logger.NewLineDecrypter(keys)
+		}
+		n, readErr := file.Read(buffer)
+		pending = append(pending, buffer[:n]...)
+		for {
+			i := bytes.IndexByte(pending, '\n')
+			if i < 0 {
+				break
+			}
+			entry, ok, decryptErr := decrypter.Decrypt(pending[:i])
+			if decryptErr != nil {
+				return decryptErr
+			}
+			if ok {
+				emit(entry)
+			}
+			pending = pending[i+1:]
+		}
+		switch {
+		case readErr == io.EOF && rotated(file, logName):
+			//the file is only switched once it has been read to the end so no entry is lost
+			file.Close()
+			file = nil
+		case readErr == io.EOF:
+			writer.Flush()
+			time.Sleep(followInterval)
+		case readErr != nil:
+			return readErr
+		}
+	}
+}
+
+//rotated returns whether or not the open file has been rotated away or truncated
+func rotated(file *os.File, logName string) bool {
+	info, err := os.Stat(logName)
+	if err != nil {
+		return false
+	}
+	current, err := file.Stat()
+	if err != nil {
+		return true
+	}
+	offset, _ := file.Seek(0, io.SeekCurrent)
+
+	return !os.SameFile(current, info) || info.Size() < offset
+}
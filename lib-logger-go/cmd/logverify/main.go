@@ -0,0 +1,95 @@
+package main
+
+//---------------------------------------------------------------------------------------------------
+// logverify verifies the hash chain of a log file and its backups, detecting deleted, reordered or
+// modified entries as well as deleted files
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"os"
+
+	logger "github.com/nationaloilwellvarco/max-edge/lib-logger-go"
+)
+
+func main() {
+	var publicKey ed25519.PublicKey
+	var keys []logger.EncryptionKey
+
+	keyFile := flag.String("key", "", "PEM file of the ed25519 public key used to verify the footers")
+	encryptionKeyFile := flag.String("keys", "", "file holding the keys of encrypted log files")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-key public.pem] [-keys keys.txt] <log file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *keyFile != "" {
+		var err error
+		if publicKey, err = logger.LoadVerifyKey(*keyFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if *encryptionKeyFile != "" {
+		var err error
+		if keys, err = logger.LoadEncryptionKeys(*encryptionKeyFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	files, err := logger.LogFiles(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "no log files found for %s\n", flag.Arg(0))
+		os.Exit(2)
+	}
+	failed := false
+	previous := ""
+	for i, file := range files {
+		result, err := verify(file, publicKey, keys)
+		switch {
+		case err != nil:
+			fmt.Printf("FAIL %s: %s\n", file, err)
+			failed = true
+		case i > 0 && previous != "" && result.Previous != previous:
+			//the header of each file links to the last entry of the file before it
+			fmt.Printf("FAIL %s: not chained to the previous file, a file may be missing\n", file)
+			failed = true
+		case !result.Sealed && i < len(files)-1:
+			//only the active file may be missing its footer
+			fmt.Printf("FAIL %s: %d entries, footer missing\n", file, result.Entries)
+			failed = true
+		case !result.Sealed:
+			fmt.Printf("OK   %s: %d entries, active file\n", file, result.Entries)
+		case publicKey != nil && !result.Signed:
+			fmt.Printf("FAIL %s: %d entries, footer not signed\n", file, result.Entries)
+			failed = true
+		default:
+			fmt.Printf("OK   %s: %d entries, sealed\n", file, result.Entries)
+		}
+		previous = result.Last
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+//verify verifies the hash chain of a single file, decrypting it if needed
+func verify(file string, publicKey ed25519.PublicKey, keys []logger.EncryptionKey) (result logger.ChainResult, err error) {
+	reader, err := logger.OpenPlainLogFile(file, keys)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	return logger.VerifyChain(reader, publicKey)
+}
@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"context"
+)
+
+type contextKey string
+
+//context keys used to carry information about the caller
+const (
+	contextKeyActor   contextKey = "actor"
+	contextKeySource  contextKey = "source"
+	contextKeyTraceID contextKey = "traceID"
+	contextKeySpanID  contextKey = "spanID"
+)
+
+//WithActor returns a context that identifies who is making a change, e.g. a user or client id
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, contextKeyActor, actor)
+}
+
+//WithSource returns a context that identifies where a change comes from, e.g. AuditSourceHTTP
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, contextKeySource, source)
+}
+
+//actorFromContext returns the actor stored in the context if any
+func actorFromContext(ctx context.Context) (actor string) {
+	if ctx != nil {
+		actor, _ = ctx.Value(contextKeyActor).(string)
+	}
+
+	return
+}
+
+//sourceFromContext returns the source stored in the context if any
+func sourceFromContext(ctx context.Context) (source string) {
+	if ctx != nil {
+		source, _ = ctx.Value(contextKeySource).(string)
+	}
+
+	return
+}
@@ -0,0 +1,237 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// Encrypts the entries written to the log files with AES-GCM. Each file starts with a plaintext
+// header holding the id of the key used so that keys can be rotated, every following line is the
+// base64 encoded nonce and ciphertext of a single entry. The key id and the line number are
+// authenticated with each entry so that lines can't be moved within a file or to another file.
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//encryption constants
+const (
+	EncryptionAlgorithm string = "aes-gcm"
+)
+
+//EncryptionKey is an AES key (16, 24 or 32 bytes) and the id stored in the header of the files
+// encrypted with it
+type EncryptionKey struct {
+	ID  string
+	Key []byte
+}
+
+//EncryptionHeader is the plaintext first line of an encrypted file
+type EncryptionHeader struct {
+	Algorithm string `json:"enc"`
+	KeyID     string `json:"kid"`
+}
+
+//entryCipher encrypts the entries of a file
+type entryCipher struct {
+	key  EncryptionKey
+	aead cipher.AEAD
+}
+
+func newAEAD(key []byte) (aead cipher.AEAD, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func newEntryCipher(key EncryptionKey) (*entryCipher, error) {
+	aead, err := newAEAD(key.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entryCipher{
+		key:  key,
+		aead: aead,
+	}, nil
+}
+
+//header returns the plaintext header of a new file
+func (c *entryCipher) header() []byte {
+	header, _ := json.Marshal(EncryptionHeader{
+		Algorithm: EncryptionAlgorithm,
+		KeyID:     c.key.ID,
+	})
+
+	return append(header, '\n')
+}
+
+//additionalData returns the data authenticated with the entry at a line of a file, key ids can't
+// contain a colon
+func additionalData(keyID string, lineNumber int64) []byte {
+	return strconv.AppendInt(append([]byte(keyID), ':'), lineNumber, 10)
+}
+
+//encrypt returns the encrypted line of an entry written at a line of the file, the header being the
+// first line
+func (c *entryCipher) encrypt(entry []byte, lineNumber int64) (line []byte, err error) {
+	entry = bytes.TrimRight(entry, "\n")
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(entry)+c.aead.Overhead())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return
+	}
+	sealed := c.aead.Seal(nonce, nonce, entry, additionalData(c.key.ID, lineNumber))
+	line = make([]byte, base64.StdEncoding.EncodedLen(len(sealed))+1)
+	base64.StdEncoding.Encode(line, sealed)
+	line[len(line)-1] = '\n'
+
+	return
+}
+
+//encryptedSize returns the size of the encrypted line of an entry of the given size
+func (c *entryCipher) encryptedSize(size int) int {
+	return base64.StdEncoding.EncodedLen(c.aead.NonceSize()+size+c.aead.Overhead()) + 1
+}
+
+//ParseEncryptionKey parses a key of the form <id>:<base64 key>
+func ParseEncryptionKey(value string) (key EncryptionKey, err error) {
+	parts := strings.SplitN(strings.TrimSpace(value), ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		err = ErrEncryptionKeyFormat
+
+		return
+	}
+	key.ID = parts[0]
+	if key.Key, err = base64.StdEncoding.DecodeString(parts[1]); err != nil {
+		return
+	}
+	//ensure the key has a valid size
+	_, err = aes.NewCipher(key.Key)
+
+	return
+}
+
+//LoadEncryptionKeys reads keys of the form <id>:<base64 key> from a file, one key per line, the
+// first key is the one used for encryption
+func LoadEncryptionKeys(path string) (keys []EncryptionKey, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var key EncryptionKey
+		if key, err = ParseEncryptionKey(line); err != nil {
+			return
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		err = ErrEncryptionKeyNotFound
+	}
+
+	return
+}
+
+//DecryptLog streams the plaintext of an encrypted log file to the writer, the key of the file is
+// looked up by the id found in its header
+func DecryptLog(reader io.Reader, writer io.Writer, keys []EncryptionKey) (err error) {
+	decrypter := NewLineDecrypter(keys)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 32*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Bytes()
+		if lineNumber == 1 && !IsEncryptedLog(line) {
+			err = ErrEncryptionHeader
+
+			return
+		}
+		entry, ok, decryptErr := decrypter.Decrypt(line)
+		if decryptErr != nil {
+			return decryptErr
+		}
+		if !ok {
+			continue
+		}
+		if _, err = writer.Write(append(entry, '\n')); err != nil {
+			return
+		}
+	}
+
+	return scanner.Err()
+}
+
+//LineDecrypter decrypts the lines of a log file one at a time, e.g. while the file is followed, the
+// lines of a file that isn't encrypted are returned as they are
+type LineDecrypter struct {
+	keys       []EncryptionKey
+	keyID      string      //id of the key of the file
+	aead       cipher.AEAD //nil if the file isn't encrypted
+	lineNumber int64
+}
+
+//NewLineDecrypter returns a decrypter for the lines of a single file, starting with its first line
+func NewLineDecrypter(keys []EncryptionKey) *LineDecrypter {
+	return &LineDecrypter{
+		keys: keys,
+	}
+}
+
+//Decrypt returns the plaintext of the next line of the file, the header of an encrypted file has no
+// plaintext and isn't ok
+func (d *LineDecrypter) Decrypt(line []byte) (entry []byte, ok bool, err error) {
+	var header EncryptionHeader
+
+	d.lineNumber++
+	if d.lineNumber == 1 && IsEncryptedLog(line) {
+		json.Unmarshal(line, &header)
+		for _, key := range d.keys {
+			if key.ID == header.KeyID {
+				d.keyID = key.ID
+				d.aead, err = newAEAD(key.Key)
+				return
+			}
+		}
+		err = ErrEncryptionKeyIDf.Withf(header.KeyID)
+
+		return
+	}
+	if d.aead == nil {
+		return line, true, nil
+	}
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(line)))
+	n, decodeErr := base64.StdEncoding.Decode(sealed, line)
+	if decodeErr != nil || n < d.aead.NonceSize() {
+		err = ErrDecryptf.Withf(d.lineNumber)
+
+		return
+	}
+	sealed = sealed[:n]
+	if entry, err = d.aead.Open(nil, sealed[:d.aead.NonceSize()], sealed[d.aead.NonceSize():], additionalData(d.keyID, d.lineNumber)); err != nil {
+		err = ErrDecryptf.Withf(d.lineNumber)
+
+		return
+	}
+	ok = true
+
+	return
+}
+
+//IsEncryptedLog returns whether or not the first line is the header of an encrypted log file
+func IsEncryptedLog(firstLine []byte) bool {
+	var header EncryptionHeader
+
+	return json.Unmarshal(firstLine, &header) == nil && header.Algorithm == EncryptionAlgorithm
+}
@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+)
+
+//encryptedFile writes the entries to an encrypted file and returns its path
+func encryptedFile(t *testing.T, key EncryptionKey, entries ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.log")
+	writeEncrypted(t, path, key, entries...)
+
+	return path
+}
+
+//writeEncrypted writes the entries to an encrypted file, continuing it if it exists
+func writeEncrypted(t *testing.T, path string, key EncryptionKey, entries ...string) {
+	t.Helper()
+
+	cipher, err := newEntryCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := newFileSink(&lumberjack.Logger{Filename: path, MaxSize: 1}, nil, cipher, []EncryptionKey{key})
+	for _, entry := range entries {
+		if _, err = sink.Write([]byte(entry + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//decryptFile returns the plaintext of a file
+func decryptFile(path string, keys ...EncryptionKey) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var plain bytes.Buffer
+	err = DecryptLog(file, &plain, keys)
+
+	return plain.String(), err
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := newTestEncryptionKey(t, "key")
+	path := encryptedFile(t, key, "first", "second")
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte("first")) {
+		t.Fatalf("entry written in plaintext: %s", content)
+	}
+	if plain, err := decryptFile(path, key); err != nil || plain != "first\nsecond\n" {
+		t.Errorf("decrypted %q, %v", plain, err)
+	}
+	//the file is continued after a restart
+	writeEncrypted(t, path, key, "third")
+	if files, _ := LogFiles(path); len(files) != 1 {
+		t.Errorf("restart rotated the file into %v", files)
+	}
+	if plain, err := decryptFile(path, key); err != nil || plain != "first\nsecond\nthird\n" {
+		t.Errorf("decrypted %q after a restart, %v", plain, err)
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	key, other := newTestEncryptionKey(t, "key"), newTestEncryptionKey(t, "other")
+
+	//the key of the file must be known
+	path := encryptedFile(t, key, "entry")
+	if _, err := decryptFile(path, other); !errors.Is(err, ErrEncryptionKeyIDf) {
+		t.Errorf("decrypted without the key with %v", err)
+	}
+	//a key with the same id but another value doesn't decrypt the file
+	if _, err := decryptFile(path, EncryptionKey{ID: key.ID, Key: other.Key}); !errors.Is(err, ErrDecryptf) {
+		t.Errorf("decrypted with another key with %v", err)
+	}
+	//entries are bound to their line, swapped lines aren't decrypted
+	path = encryptedFile(t, key, "first", "second")
+	lines := readLines(t, path)
+	lines[1], lines[2] = lines[2], lines[1]
+	writeLines(t, path, lines)
+	if _, err := decryptFile(path, key); !errors.Is(err, ErrDecryptf) {
+		t.Errorf("swapped lines decrypted with %v", err)
+	}
+	//entries are bound to their key, a line moved to a file of another key isn't decrypted
+	source := readLines(t, encryptedFile(t, key, "entry"))
+	path = encryptedFile(t, other, "entry")
+	lines = readLines(t, path)
+	lines[1] = source[1]
+	writeLines(t, path, lines)
+	if _, err := decryptFile(path, key, other); !errors.Is(err, ErrDecryptf) {
+		t.Errorf("line of another key decrypted with %v", err)
+	}
+	//files must start with the header
+	path = filepath.Join(t.TempDir(), "plain.log")
+	os.WriteFile(path, []byte("entry\n"), 0600)
+	if _, err := decryptFile(path, key); !errors.Is(err, ErrEncryptionHeader) {
+		t.Errorf("plaintext file decrypted with %v", err)
+	}
+}
+
+func TestEncryptedLogger(t *testing.T) {
+	inTempDir(t)
+
+	key := newTestEncryptionKey(t, "key")
+	l := newTestLogger(t, map[string]string{
+		EnvNameLogEncryptionKey: key.ID + ":" + base64.StdEncoding.EncodeToString(key.Key),
+	})
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	//capture stdout while logging
+	stdout := os.Stdout
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = writer
+	l.Info("secret entry")
+	os.Stdout = stdout
+	writer.Close()
+	printed, _ := io.ReadAll(reader)
+	if len(printed) > 0 {
+		t.Errorf("encrypted entry printed as %s", printed)
+	}
+	if err = l.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := decryptFile("test.log", key)
+	if err != nil || !strings.Contains(plain, "secret entry") {
+		t.Errorf("decrypted %q, %v", plain, err)
+	}
+}
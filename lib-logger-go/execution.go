@@ -2,27 +2,152 @@ package logger
 
 import (
 	"strconv"
+	"sync"
 	"time"
 )
 
+//config is a snapshot of the configuration, each logger keeps the snapshot taken by Configure so
+// that it never reads the configuration variables while they're changed
+type config struct {
+	debugTimer           time.Duration
+	auditHistory         int
+	dedupInterval        time.Duration
+	summaryInterval      time.Duration
+	summaryTopN          int
+	otlpEndpoint         string
+	otlpFile             string
+	otlpInstance         string
+	otlpInterval         time.Duration
+	logChain             bool
+	logChainKey          string
+	logEncryptionKey     string
+	logEncryptionKeyFile string
+}
+
+//configMu guards the configuration variables
+var configMu sync.Mutex
+
 //ConfigureFromEnv will take a map of environmental variables and attempt to derive the internal configuration
 func ConfigureFromEnv(envs map[string]string) {
+	parseConfig(envs).apply()
+}
+
+//parseConfig derives the configuration from the environmental variables without applying it
+func parseConfig(envs map[string]string) (c *config) {
+	c = &config{}
 	//get debug timer from environment
 	if debugTimeString, ok := envs[EnvNameDebugTimer]; !ok {
 		//use default if not found
-		ConfigDebugTimer = DefaultDebugTimer
+		c.debugTimer = DefaultDebugTimer
 	} else {
 		if debugTimeString != "" {
 			//if the string is not empty, convert to integer, then to minutes
 			if minutes, err := strconv.Atoi(debugTimeString); err != nil || minutes <= 0 {
 				//use default time if there is an error or minutes is less or equal to 0
-				ConfigDebugTimer = DefaultDebugTimer
+				c.debugTimer = DefaultDebugTimer
 			} else {
-				ConfigDebugTimer = time.Duration(minutes) * time.Minute
+				c.debugTimer = time.Duration(minutes) * time.Minute
 			}
 		} else {
 			//if not found, use the default debug time
-			ConfigDebugTimer = DefaultDebugTimer
+			c.debugTimer = DefaultDebugTimer
+		}
+	}
+	//get the size of the audit history from environment
+	c.auditHistory = DefaultAuditHistory
+	if auditHistoryString, ok := envs[EnvNameAuditHistory]; ok && auditHistoryString != "" {
+		//use default size if there is an error or the size is less or equal to 0
+		if size, err := strconv.Atoi(auditHistoryString); err == nil && size > 0 {
+			c.auditHistory = size
+		}
+	}
+	//get the deduplication interval in seconds from environment
+	c.dedupInterval = DefaultDedupInterval
+	if dedupIntervalString, ok := envs[EnvNameDedupInterval]; ok && dedupIntervalString != "" {
+		//leave deduplication disabled if there is an error or the interval is less or equal to 0
+		if seconds, err := strconv.Atoi(dedupIntervalString); err == nil && seconds > 0 {
+			c.dedupInterval = time.Duration(seconds) * time.Second
+		}
+	}
+	//get the summary interval in seconds from environment
+	c.summaryInterval = DefaultSummaryInterval
+	if summaryIntervalString, ok := envs[EnvNameSummaryInterval]; ok && summaryIntervalString != "" {
+		//leave summaries disabled if there is an error or the interval is less or equal to 0
+		if seconds, err := strconv.Atoi(summaryIntervalString); err == nil && seconds > 0 {
+			c.summaryInterval = time.Duration(seconds) * time.Second
+		}
+	}
+	//get the number of most frequent messages in a summary from environment
+	c.summaryTopN = DefaultSummaryTopN
+	if summaryTopNString, ok := envs[EnvNameSummaryTopN]; ok && summaryTopNString != "" {
+		if topN, err := strconv.Atoi(summaryTopNString); err == nil && topN >= 0 {
+			c.summaryTopN = topN
 		}
 	}
+	//get where the entries are exported to as OTLP log records
+	c.otlpEndpoint = envs[EnvNameOTLPEndpoint]
+	c.otlpFile = envs[EnvNameOTLPFile]
+	c.otlpInstance = envs[EnvNameOTLPInstance]
+	//get the export interval in seconds from environment
+	c.otlpInterval = DefaultOTLPInterval
+	if otlpIntervalString, ok := envs[EnvNameOTLPInterval]; ok && otlpIntervalString != "" {
+		if seconds, err := strconv.Atoi(otlpIntervalString); err == nil && seconds > 0 {
+			c.otlpInterval = time.Duration(seconds) * time.Second
+		}
+	}
+	//get whether or not the log files are hash chained and the key signing them
+	c.logChain, _ = strconv.ParseBool(envs[EnvNameLogChain])
+	c.logChainKey = envs[EnvNameLogChainKey]
+	//get the key encrypting the log files, the key itself takes precedence over a key file
+	c.logEncryptionKey = envs[EnvNameLogEncryptionKey]
+	c.logEncryptionKeyFile = envs[EnvNameLogEncryptionKeyFile]
+
+	return
+}
+
+//encrypted returns whether or not the log files are encrypted
+func (c *config) encrypted() bool {
+	return c.logEncryptionKey != "" || c.logEncryptionKeyFile != ""
+}
+
+//apply sets the configuration variables
+func (c *config) apply() {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	ConfigDebugTimer = c.debugTimer
+	ConfigAuditHistory = c.auditHistory
+	ConfigDedupInterval = c.dedupInterval
+	ConfigSummaryInterval = c.summaryInterval
+	ConfigSummaryTopN = c.summaryTopN
+	ConfigOTLPEndpoint = c.otlpEndpoint
+	ConfigOTLPFile = c.otlpFile
+	ConfigOTLPInstance = c.otlpInstance
+	ConfigOTLPInterval = c.otlpInterval
+	ConfigLogChain = c.logChain
+	ConfigLogChainKey = c.logChainKey
+	ConfigLogEncryptionKey = c.logEncryptionKey
+	ConfigLogEncryptionKeyFile = c.logEncryptionKeyFile
+}
+
+//currentConfig returns a snapshot of the configuration variables
+func currentConfig() *config {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	return &config{
+		debugTimer:           ConfigDebugTimer,
+		auditHistory:         ConfigAuditHistory,
+		dedupInterval:        ConfigDedupInterval,
+		summaryInterval:      ConfigSummaryInterval,
+		summaryTopN:          ConfigSummaryTopN,
+		otlpEndpoint:         ConfigOTLPEndpoint,
+		otlpFile:             ConfigOTLPFile,
+		otlpInstance:         ConfigOTLPInstance,
+		otlpInterval:         ConfigOTLPInterval,
+		logChain:             ConfigLogChain,
+		logChainKey:          ConfigLogChainKey,
+		logEncryptionKey:     ConfigLogEncryptionKey,
+		logEncryptionKeyFile: ConfigLogEncryptionKeyFile,
+	}
 }
@@ -0,0 +1,110 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// Helpers to find and read a log file together with the backups created by lumberjack
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//backupTimeFormat is the format lumberjack uses for the timestamp in the name of a backup
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+
+	return g.file.Close()
+}
+
+//LogFiles returns the backups of a log file, oldest first, followed by the log file itself if it
+// exists, compressed backups are included
+func LogFiles(logName string) (files []string, err error) {
+	ext := filepath.Ext(logName)
+	prefix := strings.TrimSuffix(logName, ext) + "-"
+	matches, err := filepath.Glob(prefix + "*" + ext + "*")
+	if err != nil {
+		return
+	}
+	for _, match := range matches {
+		//only keep names of the form <prefix><timestamp><ext>[.gz]
+		timestamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(match, prefix), ".gz"), ext)
+		if len(timestamp) == len(backupTimeFormat) {
+			files = append(files, match)
+		}
+	}
+	//the timestamp format sorts lexically
+	sort.Strings(files)
+	if _, statErr := os.Stat(logName); statErr == nil {
+		files = append(files, logName)
+	}
+
+	return
+}
+
+//OpenLogFile opens a log file for reading, decompressing it if it's a compressed backup
+func OpenLogFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+
+		return nil, err
+	}
+
+	return &gzipFile{Reader: reader, file: file}, nil
+}
+
+type plainFile struct {
+	*io.PipeReader
+	file io.Closer
+}
+
+func (p *plainFile) Close() error {
+	p.PipeReader.Close()
+
+	return p.file.Close()
+}
+
+//OpenPlainLogFile opens a log file for reading like OpenLogFile, an encrypted file is decrypted
+// with the matching key while it's read
+func OpenPlainLogFile(path string, keys []EncryptionKey) (io.ReadCloser, error) {
+	file, err := OpenLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(file)
+	firstLine, _ := reader.Peek(256)
+	if i := strings.IndexByte(string(firstLine), '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if !IsEncryptedLog(firstLine) {
+		return &struct {
+			io.Reader
+			io.Closer
+		}{reader, file}, nil
+	}
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		pipeWriter.CloseWithError(DecryptLog(reader, pipeWriter, keys))
+	}()
+
+	return &plainFile{PipeReader: pipeReader, file: file}, nil
+}
@@ -0,0 +1,226 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// Reports how the logger is set up at runtime so that support engineers can see the effective
+// configuration of a node, the files written and whether writing to them fails
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+//sink types
+const (
+	SinkTypeFile   string = "file"
+	SinkTypeStdout string = "stdout"
+	SinkTypeOTLP   string = "otlp"
+)
+
+//Introspection is the effective configuration and state of the logger
+type Introspection struct {
+	CommonName string                 `json:"commonName"`
+	State      string                 `json:"state"`  //new, configured, started or closed
+	LogDir     string                 `json:"logDir"` //directory the log files are written to
+	Sinks      []SinkInfo             `json:"sinks"`
+	Debug      DebugInfo              `json:"debug"`
+	Sampling   []SamplingRule         `json:"sampling"`
+	Settings   map[string]interface{} `json:"settings"` //configuration read from the environment
+}
+
+//SinkInfo describes where entries are written and whether writing to it succeeds
+type SinkInfo struct {
+	Type          string    `json:"type"`               //file, stdout or otlp
+	Target        string    `json:"target,omitempty"`   //path of the file or endpoint of the collector
+	Services      []string  `json:"services,omitempty"` //services routed to the file
+	CatchAll      bool      `json:"catchAll,omitempty"` //whether or not the file receives every service that isn't routed
+	Size          int64     `json:"size,omitempty"`     //current size of the file in bytes
+	Rotation      *Rotation `json:"rotation,omitempty"` //rotation of the file
+	Chained       bool      `json:"chained,omitempty"`
+	Encrypted     bool      `json:"encrypted,omitempty"`
+	Healthy       bool      `json:"healthy"` //whether or not the last write succeeded
+	Writes        int64     `json:"writes"`
+	WriteErrors   int64     `json:"writeErrors"`
+	LastError     string    `json:"lastError,omitempty"`
+	LastErrorTime time.Time `json:"lastErrorTime"`
+}
+
+//DebugInfo is the debug state of the logger
+type DebugInfo struct {
+	System   bool            `json:"system"`   //whether or not debug is enabled for everything
+	Deadline time.Time       `json:"deadline"` //when the current debug session expires, zero if there is none
+	Services map[string]bool `json:"services"` //debug mode of every known service
+}
+
+//sinkError is the last error of a sink
+type sinkError struct {
+	message string
+	time    time.Time
+}
+
+//sinkStats counts the writes of a sink, the counters are accessed atomically
+type sinkStats struct {
+	writes    int64
+	errors    int64
+	failing   int32        //whether or not the last write failed
+	lastError atomic.Value //holds a sinkError
+}
+
+//record counts a write and its error if any
+func (s *sinkStats) record(err error) {
+	atomic.AddInt64(&s.writes, 1)
+	if err == nil {
+		atomic.StoreInt32(&s.failing, 0)
+		return
+	}
+	atomic.AddInt64(&s.errors, 1)
+	atomic.StoreInt32(&s.failing, 1)
+	s.lastError.Store(sinkError{message: err.Error(), time: time.Now()})
+}
+
+//fill copies the counters to the sink info
+func (s *sinkStats) fill(info *SinkInfo) {
+	info.Writes = atomic.LoadInt64(&s.writes)
+	info.WriteErrors = atomic.LoadInt64(&s.errors)
+	info.Healthy = atomic.LoadInt32(&s.failing) == 0
+	if lastError, ok := s.lastError.Load().(sinkError); ok {
+		info.LastError, info.LastErrorTime = lastError.message, lastError.time
+	}
+}
+
+//fileWriter is the writer of a log file, it counts the writes so that failures can be reported
+type fileWriter struct {
+	io.Writer
+	closer    io.Closer
+	path      string
+	rotation  Rotation
+	chained   bool
+	encrypted bool
+	stats     sinkStats
+}
+
+func (w *fileWriter) Write(p []byte) (n int, err error) {
+	n, err = w.Writer.Write(p)
+	w.stats.record(err)
+
+	return
+}
+
+func (w *fileWriter) Close() error {
+	return w.closer.Close()
+}
+
+//info describes the file
+func (w *fileWriter) info() (info SinkInfo) {
+	rotation := w.rotation
+	info = SinkInfo{
+		Type:      SinkTypeFile,
+		Target:    w.path,
+		Rotation:  &rotation,
+		Chained:   w.chained,
+		Encrypted: w.encrypted,
+	}
+	if stat, err := os.Stat(w.path); err == nil {
+		info.Size = stat.Size()
+	}
+	w.stats.fill(&info)
+
+	return
+}
+
+//stateName returns the name of a state of the logger
+func stateName(state int32) string {
+	switch state {
+	case stateConfigured:
+		return "configured"
+	case stateStarted:
+		return "started"
+	case stateClosed:
+		return "closed"
+	default:
+		return "new"
+	}
+}
+
+//Introspect returns the effective configuration and state of the logger
+func (l *logger) Introspect() (introspection Introspection) {
+	settings := l.settings()
+	introspection = Introspection{
+		CommonName: l.getCommonName(),
+		State:      stateName(atomic.LoadInt32(&l.state)),
+		Sampling:   l.sampler.list(),
+		Settings: map[string]interface{}{
+			EnvNameDebugTimer:      settings.debugTimer.String(),
+			EnvNameAuditHistory:    settings.auditHistory,
+			EnvNameDedupInterval:   settings.dedupInterval.String(),
+			EnvNameSummaryInterval: settings.summaryInterval.String(),
+			EnvNameSummaryTopN:     settings.summaryTopN,
+			EnvNameLogChain:        settings.logChain,
+			EnvNameOTLPInstance:    settings.otlpInstance,
+			EnvNameOTLPInterval:    settings.otlpInterval.String(),
+		},
+	}
+	//files, the catch-all file comes first
+	l.routesMu.RLock()
+	introspection.LogDir = l.logDir
+	if writer, ok := zapCloser.(*fileWriter); ok {
+		info := writer.info()
+		info.CatchAll = true
+		introspection.Sinks = append(introspection.Sinks, info)
+	}
+	for _, closer := range l.routedClosers {
+		writer, ok := closer.(*fileWriter)
+		if !ok {
+			continue
+		}
+		info := writer.info()
+		for _, route := range l.routes {
+			if filepath.Join(l.logDir, route.Filename) == writer.path {
+				info.Services = append(info.Services, route.Services...)
+			}
+		}
+		sort.Strings(info.Services)
+		introspection.Sinks = append(introspection.Sinks, info)
+	}
+	l.routesMu.RUnlock()
+	//standard output and the collector
+	stdout := SinkInfo{Type: SinkTypeStdout}
+	l.stdoutStats.fill(&stdout)
+	introspection.Sinks = append(introspection.Sinks, stdout)
+	if otlp, ok := l.otlp.info(); ok {
+		introspection.Sinks = append(introspection.Sinks, otlp)
+	}
+	//debug state
+	l.debugModeMap.mu.RLock()
+	introspection.Debug = DebugInfo{
+		System:   atomic.LoadInt32(&l.systemDebug) != 0,
+		Deadline: l.debugDeadline,
+		Services: make(map[string]bool),
+	}
+	for serviceName, mode := range l.debugModeMap.debugMode {
+		introspection.Debug.Services[serviceName] = mode
+	}
+	l.debugModeMap.mu.RUnlock()
+
+	return
+}
+
+//IntrospectionHandler returns a handle function responding with the introspection of the logger,
+// e.g. to be added as a route of the router
+func IntrospectionHandler(l Logger) func(http.ResponseWriter, *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		bytes, err := json.Marshal(l.Introspect())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Write(bytes)
+	}
+}
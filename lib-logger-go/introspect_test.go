@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestSinkStats(t *testing.T) {
+	writer := &fileWriter{Writer: failingWriter{}, path: "missing.log", rotation: DefaultRotation}
+
+	writer.Write([]byte("entry"))
+	info := writer.info()
+	if info.Healthy || info.Writes != 1 || info.WriteErrors != 1 || info.LastError != "disk full" || info.LastErrorTime.IsZero() {
+		t.Errorf("failed write reported as %+v", info)
+	}
+	if info.Type != SinkTypeFile || info.Target != "missing.log" || info.Size != 0 || *info.Rotation != DefaultRotation {
+		t.Errorf("file reported as %+v", info)
+	}
+	//a write that succeeds makes the sink healthy but keeps the last error
+	writer.Writer = io.Discard
+	writer.Write([]byte("entry"))
+	if info = writer.info(); !info.Healthy || info.Writes != 2 || info.WriteErrors != 1 || info.LastError != "disk full" {
+		t.Errorf("recovered write reported as %+v", info)
+	}
+}
+
+func TestIntrospect(t *testing.T) {
+	inTempDir(t)
+
+	if state := NewLogger().Introspect().State; state != "new" {
+		t.Errorf("new logger introspected as %s", state)
+	}
+	l := newTestLogger(t, map[string]string{EnvNameOTLPFile: "otlp.json", EnvNameSummaryTopN: "3"}, "a", "b")
+	if err := l.ConfigureRoutes(LogRoute{Filename: "ab.log", Services: []string{"b", "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	l.ConfigureSampling(SamplingRule{ServiceName: "a", First: 1, Tick: time.Second})
+	l.EnableDebug("a")
+	l.InfoService("a", "routed entry")
+	l.Info("common entry")
+
+	introspection := l.Introspect()
+	if introspection.CommonName != "test" || introspection.State != "configured" || introspection.Settings[EnvNameSummaryTopN] != 3 {
+		t.Errorf("logger introspected as %+v", introspection)
+	}
+	if len(introspection.Sampling) != 1 || introspection.Sampling[0].ServiceName != "a" {
+		t.Errorf("sampling introspected as %+v", introspection.Sampling)
+	}
+	if debug := introspection.Debug; debug.System || !debug.Services["a"] || debug.Services["b"] || debug.Deadline.IsZero() {
+		t.Errorf("debug introspected as %+v", debug)
+	}
+	//the common file comes first, then the routed files, standard output and the collector
+	sinks := introspection.Sinks
+	if len(sinks) != 4 {
+		t.Fatalf("sinks introspected as %+v", sinks)
+	}
+	if common := sinks[0]; !common.CatchAll || filepath.Base(common.Target) != "test.log" || common.Size == 0 || !common.Healthy || common.Writes == 0 {
+		t.Errorf("common file introspected as %+v", common)
+	}
+	if routed := sinks[1]; routed.CatchAll || filepath.Base(routed.Target) != "ab.log" || len(routed.Services) != 2 || routed.Services[0] != "a" || routed.Writes != 1 {
+		t.Errorf("routed file introspected as %+v", routed)
+	}
+	if stdout := sinks[2]; stdout.Type != SinkTypeStdout || stdout.Writes < 2 || !stdout.Healthy {
+		t.Errorf("standard output introspected as %+v", stdout)
+	}
+	if otlp := sinks[3]; otlp.Type != SinkTypeOTLP || filepath.Base(otlp.Target) != "otlp.json" {
+		t.Errorf("collector introspected as %+v", otlp)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if state := l.Introspect().State; state != "closed" {
+		t.Errorf("closed logger introspected as %s", state)
+	}
+}
+
+func TestIntrospectionHandler(t *testing.T) {
+	inTempDir(t)
+
+	l := newTestLogger(t, nil, "a")
+	recorder := httptest.NewRecorder()
+	IntrospectionHandler(l)(recorder, httptest.NewRequest(http.MethodGet, "/logger", nil))
+
+	var introspection Introspection
+	if err := json.NewDecoder(recorder.Body).Decode(&introspection); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("introspection responded %d %v", recorder.Code, recorder.Header())
+	}
+	if introspection.CommonName != "test" || len(introspection.Sinks) == 0 || len(introspection.Debug.Services) != 1 {
+		t.Errorf("introspection responded %+v", introspection)
+	}
+}
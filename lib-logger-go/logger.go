@@ -6,15 +6,21 @@ package logger
 //---------------------------------------------------------------------------------------------------
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	catalog "github.com/nationaloilwellvarco/max-edge/lib-errors-go/catalog"
+	"go.uber.org/zap"
 )
 
 //ensure that logger implements the Logger interface
@@ -41,10 +47,16 @@ type Logger interface {
 	IsDebugEnabled(serviceName ...string) bool
 	EnableDebug(serviceName ...string)
 	DisableDebug(serviceName ...string)
+	EnableDebugContext(ctx context.Context, serviceName ...string)
+	DisableDebugContext(ctx context.Context, serviceName ...string)
 	UpdateDebugMap(serviceName string, status bool)
 	SetSystemDebugStatus(bool)
+	SetSystemDebugStatusContext(ctx context.Context, status bool)
 	GetSystemDebugStatus() bool
 	CheckDebugMap(serviceName string) bool
+	GetAuditHistory(filter AuditFilter) []AuditEvent
+	LogContext(ctx context.Context, severity, serviceName, content string)
+	Introspect() Introspection
 }
 
 type ServiceDebug struct {
@@ -54,9 +66,14 @@ type ServiceDebug struct {
 
 // Owner interface
 type Owner interface {
-	Configure(commonName string, envs map[string]string)
+	Configure(commonName string, envs map[string]string) error
+	ConfigureRoutes(routes ...LogRoute) error
+	ConfigureSampling(rules ...SamplingRule)
+	SetSummaryPublisher(publisher Publisher, topicName string)
+	SetExporter(exporter Exporter) error
+	SetTraceExtractor(extractor TraceExtractor)
 
-	Close()
+	Close() error
 }
 
 // Manage interface
@@ -67,16 +84,43 @@ type Manage interface {
 	Stop() (err error)
 }
 
+//logger states, Configure moves a new logger to configured, Start and Stop move it between
+// configured and started and Close ends it
+const (
+	stateNew int32 = iota
+	stateConfigured
+	stateStarted
+	stateClosed
+)
+
 //Logger - Defines the logger object
 type logger struct {
 	sync.WaitGroup
-	sync.RWMutex //mutex for threadsafe operations
-	started      bool
-	commonName   string //used for functions called by common components
-	stopper      chan struct{}
-	systemDebug  bool
-	debugModeMap ServiceDebug
-	debug        chan bool
+	sync.RWMutex                //mutex for threadsafe operations
+	state          int32        //state of the logger, accessed atomically
+	commonName     atomic.Value //used for functions called by common components, holds a string
+	config         atomic.Value //holds the *config taken by Configure
+	stopper        chan struct{}
+	systemDebug    int32        //whether or not debug is enabled for everything, accessed atomically
+	debugModeMap   ServiceDebug //debug mode of each service, its lock also guards the debug timer
+	debugEnabled   atomic.Value //snapshot of the services in debug mode, holds a map[string]bool
+	debugServices  int32        //number of services in debug mode, accessed atomically
+	debugDeadline  time.Time    //when the current debug session expires
+	debugTimer     *time.Timer  //ends the debug session, only runs while started
+	stateMu        sync.Mutex   //serializes the saving of the debug state
+	stateStore     StateStore   //optional store used to persist the debug state
+	auditTrail     *auditTrail
+	logDir         string                 //directory the log files are written to
+	routesMu       sync.RWMutex           //mutex for the routed loggers
+	routedLoggers  map[string]*zap.Logger //loggers of services routed to their own file
+	routedClosers  []io.Closer            //files of the routed loggers
+	routes         []LogRoute             //routes configured by ConfigureRoutes
+	catchAllRouted bool                   //whether or not the catch-all file has been replaced by a route
+	sampler        *sampler               //sampling of the entries per service and level
+	deduper        *deduper               //collapses identical consecutive entries
+	reporter       *reporter              //counts the entries for the periodic summary
+	otlp           *otlp                  //exports the entries as OTLP log records
+	stdoutStats    sinkStats              //counts the writes to stdout
 }
 
 // NewLogger returns interfacce
@@ -85,116 +129,322 @@ func NewLogger() interface {
 	Owner
 	Manage
 } {
-	debug := make(chan bool)
-	return &logger{
-		debug: debug,
+	l := &logger{
+		debugModeMap: ServiceDebug{
+			debugMode: make(map[string]bool),
+			mu:        &sync.RWMutex{},
+		},
+		auditTrail: newAuditTrail(DefaultAuditHistory),
+		sampler:    newSampler(),
+		deduper:    newDeduper(),
+		reporter:   newReporter(),
+		otlp:       newOTLP(),
 	}
+	l.config.Store(parseConfig(nil))
+
+	return l
+}
+
+//settings returns the configuration taken by Configure, the defaults until it's configured
+func (l *logger) settings() *config {
+	return l.config.Load().(*config)
 }
 
-func (l *logger) Configure(commonName string, envs map[string]string) {
+//Configure can be called on a new or stopped logger, the debug mode of the known services is kept
+func (l *logger) Configure(commonName string, envs map[string]string) (err error) {
 	l.Lock()
 	defer l.Unlock()
 
-	//get configuration
-	ConfigureFromEnv(envs)
-	//set common component name
-	l.commonName = commonName
+	switch atomic.LoadInt32(&l.state) {
+	case stateStarted:
+		return ErrLoggerStarted
+	case stateClosed:
+		return ErrLoggerClosed
+	}
+	//get the configuration and validate it before anything is applied so that a logger that fails to
+	// be configured keeps its previous configuration
+	settings := parseConfig(envs)
+	logDir := ""
 	if runtime.GOOS == "windows" {
-		path := "log/"
-		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-			err := os.Mkdir(path, os.ModePerm)
+		logDir = "log/"
+		if _, err := os.Stat(logDir); errors.Is(err, os.ErrNotExist) {
+			err := os.Mkdir(logDir, os.ModePerm)
 			if err != nil {
 				log.Println(err)
 			}
 		}
-		InitLogging("log/" + commonName + ".log")
-	} else {
-		InitLogging(commonName + ".log")
 	}
-	//create the debugger map
-	l.debugModeMap = ServiceDebug{
-		debugMode: make(map[string]bool),
-		mu:        &sync.RWMutex{},
+	exporter, err := newExporter(settings)
+	if err != nil {
+		return
+	}
+	zapLogger, closer, err := newZapLogger(logDir+commonName+".log", DefaultRotation, settings)
+	if err != nil {
+		if exporter != nil {
+			exporter.Close()
+		}
+
+		return
+	}
+	//apply the configuration
+	settings.apply()
+	l.config.Store(settings)
+	//set common component name
+	l.commonName.Store(commonName)
+	//size the audit trail
+	l.auditTrail.resize(settings.auditHistory)
+	//set how often repeated entries are written
+	l.deduper.configure(settings.dedupInterval)
+	l.reporter.reset()
+	//replace the common file and drop the routes of a previous configuration
+	l.routesMu.Lock()
+	CloseLogging()
+	ZapLogger, zapCloser = zapLogger, closer
+	closeRouted(l.routedLoggers, l.routedClosers)
+	l.routedLoggers, l.routedClosers, l.routes, l.catchAllRouted = nil, nil, nil, false
+	l.logDir = logDir
+	l.routesMu.Unlock()
+	//use a state store if a state file has been configured
+	l.stateMu.Lock()
+	l.stateStore = nil
+	if stateFile, ok := envs[EnvNameStateFile]; ok && stateFile != "" {
+		l.stateStore = NewFileStateStore(stateFile)
+	}
+	l.stateMu.Unlock()
+	//export the entries if a collector or file has been configured
+	if previous := l.otlp.setExporter(exporter); previous != nil {
+		previous.Close()
+	}
+	instance := settings.otlpInstance
+	if instance == "" {
+		instance, _ = os.Hostname()
 	}
+	l.otlp.configure(instance)
+	atomic.StoreInt32(&l.state, stateConfigured)
 
+	return
 }
 
-func (l *logger) Close() {
+//Close stops the logger if started, flushes and closes every log file, the logger can't be used
+// after it's closed and calling Close again does nothing
+func (l *logger) Close() (err error) {
 	l.Lock()
 	defer l.Unlock()
 
-	//close internal pointers
-	close(l.debug)
-	//set internal pointers to nil
-	l.stopper, l.debug = nil, nil
+	switch atomic.LoadInt32(&l.state) {
+	case stateClosed:
+		return
+	case stateStarted:
+		l.stop()
+	}
+	//final flush of all writers
+	l.routesMu.Lock()
+	err = closeRouted(l.routedLoggers, l.routedClosers)
+	l.routedLoggers, l.routedClosers = nil, nil
+	if closeErr := CloseLogging(); closeErr != nil {
+		err = closeErr
+	}
+	//entries aren't written once the files are closed
+	atomic.StoreInt32(&l.state, stateClosed)
+	l.routesMu.Unlock()
+	if exporter := l.otlp.setExporter(nil); exporter != nil {
+		if closeErr := exporter.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+
+	return
 }
 
-//Start
+//checkConfigured returns an error if the logger hasn't been configured or is closed
+func (l *logger) checkConfigured() (err error) {
+	switch atomic.LoadInt32(&l.state) {
+	case stateNew:
+		err = ErrLoggerNotConfigured
+	case stateClosed:
+		err = ErrLoggerClosed
+	}
+
+	return
+}
+
+//Start can be called on a configured or stopped logger, starting a started logger does nothing
 func (l *logger) Start() (err error) {
 	l.Lock()
 	defer l.Unlock()
 
-	if l.started {
+	if atomic.LoadInt32(&l.state) == stateStarted {
+		return
+	}
+	if err = l.checkConfigured(); err != nil {
 		return
 	}
 
 	l.stopper = make(chan struct{})
-	//launch debug
-	l.LaunchDebug()
+	//restore the debug state from a previous run
+	l.restoreState()
+	//launch deduplication if enabled
+	settings := l.settings()
+	if settings.dedupInterval > 0 {
+		l.LaunchDedup()
+	}
+	//launch the summary if enabled
+	if settings.summaryInterval > 0 {
+		l.LaunchSummary()
+	}
+	//launch the export, an exporter can be set at any time
+	l.LaunchExport()
 	//set started to true
-	l.started = true
+	atomic.StoreInt32(&l.state, stateStarted)
+	//run the debug timer, debug enabled before start expires like any other debug session
+	l.debugModeMap.mu.Lock()
+	if l.debugDeadline.IsZero() && l.anyDebug() {
+		l.debugDeadline = time.Now().Add(settings.debugTimer)
+	}
+	l.startDebugTimer()
+	l.debugModeMap.mu.Unlock()
 
 	return
 }
 
-//Stop
+//Stop stops the routines of the logger and flushes the log files, the debug mode of the services
+// is kept so the logger can be started again, stopping a logger that isn't started does nothing
 func (l *logger) Stop() (err error) {
 	l.Lock()
 	defer l.Unlock()
 
-	if !l.started {
+	if atomic.LoadInt32(&l.state) != stateStarted {
 		return
 	}
-	l.debugModeMap = ServiceDebug{
-		debugMode: make(map[string]bool),
-		mu:        &sync.RWMutex{}}
+	l.stop()
+
+	return
+}
+
+//stop must be called with the lock held
+func (l *logger) stop() {
+	//pause the debug timer, the session resumes when started again
+	l.debugModeMap.mu.Lock()
+	l.stopDebugTimer(false)
+	l.debugModeMap.mu.Unlock()
 	//close stopper
 	close(l.stopper)
 	//wait for goRoutines to return
 	l.Wait()
+	l.stopper = nil
+	//flush the log files
+	l.flush()
 	//set started to false
-	l.started = false
+	atomic.StoreInt32(&l.state, stateConfigured)
+}
 
-	return
+//flush flushes every zap logger
+func (l *logger) flush() {
+	l.routesMu.RLock()
+	defer l.routesMu.RUnlock()
+
+	for _, zapLogger := range l.routedLoggers {
+		zapLogger.Sync()
+	}
+	if ZapLogger != nil {
+		ZapLogger.Sync()
+	}
+}
+
+//anyDebug returns whether or not debug is enabled for the system or any service, must be called
+// with the debug map lock held
+func (l *logger) anyDebug() bool {
+	for _, mode := range l.debugModeMap.debugMode {
+		if mode {
+			return true
+		}
+	}
+
+	return atomic.LoadInt32(&l.systemDebug) != 0
+}
+
+//resetDebugTimer starts a new debug session, must be called with the debug map lock held
+func (l *logger) resetDebugTimer() {
+	l.debugDeadline = time.Now().Add(l.settings().debugTimer)
+	l.startDebugTimer()
+}
+
+//startDebugTimer runs the timer of the debug session if the logger is started, must be called with
+// the debug map lock held
+func (l *logger) startDebugTimer() {
+	if l.debugTimer != nil {
+		l.debugTimer.Stop()
+		l.debugTimer = nil
+	}
+	if l.debugDeadline.IsZero() || atomic.LoadInt32(&l.state) != stateStarted {
+		return
+	}
+	l.debugTimer = time.AfterFunc(time.Until(l.debugDeadline), l.expireDebug)
+}
+
+//stopDebugTimer stops the timer of the debug session, the session itself is only ended if
+// endSession is true, must be called with the debug map lock held
+func (l *logger) stopDebugTimer(endSession bool) {
+	if l.debugTimer != nil {
+		l.debugTimer.Stop()
+		l.debugTimer = nil
+	}
+	if endSession {
+		l.debugDeadline = time.Time{}
+	}
+}
+
+//expireDebug is called by the debug timer, a timer that fires after its session was replaced
+// finds a deadline in the future and does nothing
+func (l *logger) expireDebug() {
+	var expired []string
+
+	l.debugModeMap.mu.Lock()
+	if l.debugDeadline.IsZero() || time.Now().Before(l.debugDeadline) {
+		l.debugModeMap.mu.Unlock()
+		return
+	}
+	//time expired, reset all services debug mode and overall
+	for serviceName, mode := range l.debugModeMap.debugMode {
+		if mode {
+			expired = append(expired, serviceName)
+		}
+		l.debugModeMap.debugMode[serviceName] = false
+	}
+	l.publishDebug()
+	systemDebug := atomic.SwapInt32(&l.systemDebug, 0) != 0
+	l.stopDebugTimer(true)
+	l.debugModeMap.mu.Unlock()
+
+	l.Info("Debug Timer Expired")
+	ctx := WithActor(WithSource(context.Background(), AuditSourceTimer), l.getCommonName())
+	for _, serviceName := range expired {
+		l.audit(ctx, serviceName, true, false, time.Time{})
+	}
+	if systemDebug {
+		l.audit(ctx, "", true, false, time.Time{})
+	}
+	l.saveState()
 }
 
 //GetDebugTime - This method retrieves the time for which debug will be run
 func (l *logger) GetDebugTime() time.Duration {
-	l.RLock()
-	defer l.RUnlock()
-
-	return ConfigDebugTimer
+	return l.settings().debugTimer
 }
 
 func (l *logger) UpdateDebugMap(serviceName string, status bool) {
 	l.debugModeMap.mu.Lock()
 	l.debugModeMap.debugMode[serviceName] = status
+	l.publishDebug()
 	l.debugModeMap.mu.Unlock()
 }
 
 //IsDebugEnabled - Returns the debug mode
 func (l *logger) IsDebugEnabled(serviceName ...string) (debugmode bool) {
-	l.RLock()
-	defer l.RUnlock()
-
 	if len(serviceName) != 0 {
-		l.debugModeMap.mu.Lock()
-		if mode, ok := l.debugModeMap.debugMode[serviceName[0]]; ok {
-			debugmode = mode
-		}
-		l.debugModeMap.mu.Unlock()
+		debugmode = l.serviceDebug(serviceName[0])
 	} else {
-		debugmode = l.systemDebug
+		debugmode = atomic.LoadInt32(&l.systemDebug) != 0
 	}
 
 	return
@@ -202,124 +452,176 @@ func (l *logger) IsDebugEnabled(serviceName ...string) (debugmode bool) {
 
 //EnableDebug - Enable Debug if not set
 func (l *logger) EnableDebug(serviceName ...string) {
-	// l.Lock()
-	// defer l.Unlock()
-	if len(serviceName) != 0 {
-		l.debugModeMap.mu.Lock()
-		if _, ok := l.debugModeMap.debugMode[serviceName[0]]; ok {
-			l.debugModeMap.debugMode[serviceName[0]] = true
-		}
-		l.debugModeMap.mu.Unlock()
-		l.debug <- true
-	} else {
-		//if service name not specified enable everything
-		l.SetSystemDebugStatus(true)
-	}
+	l.EnableDebugContext(context.Background(), serviceName...)
 }
 
 //DisableDebug - Disable Debug if set
 func (l *logger) DisableDebug(serviceName ...string) {
-	// l.RLock()
-	// defer l.RUnlock()
-	if len(serviceName) != 0 {
-		l.debugModeMap.mu.Lock()
-		if _, ok := l.debugModeMap.debugMode[serviceName[0]]; ok {
-			l.debugModeMap.debugMode[serviceName[0]] = false
-		}
-		l.debugModeMap.mu.Unlock()
-		l.debug <- false
-	} else {
+	l.DisableDebugContext(context.Background(), serviceName...)
+}
+
+//EnableDebugContext - Enable Debug if not set, the actor and source of the change are taken from the context
+func (l *logger) EnableDebugContext(ctx context.Context, serviceName ...string) {
+	if len(serviceName) == 0 {
+		//if service name not specified enable everything
+		l.SetSystemDebugStatusContext(ctx, true)
+		return
+	}
+	l.debugModeMap.mu.Lock()
+	oldState, ok := l.debugModeMap.debugMode[serviceName[0]]
+	if ok {
+		l.debugModeMap.debugMode[serviceName[0]] = true
+		l.publishDebug()
+		//setting to debug will reset the timer
+		l.resetDebugTimer()
+	}
+	expiry := l.debugDeadline
+	l.debugModeMap.mu.Unlock()
+	if ok {
+		l.Info("Debug Mode Enabled")
+		l.audit(ctx, serviceName[0], oldState, true, expiry)
+		l.saveState()
+	}
+}
+
+//DisableDebugContext - Disable Debug if set, the actor and source of the change are taken from the context
+func (l *logger) DisableDebugContext(ctx context.Context, serviceName ...string) {
+	if len(serviceName) == 0 {
 		//if service name not specified disable everything
-		l.SetSystemDebugStatus(false)
+		l.SetSystemDebugStatusContext(ctx, false)
+		return
+	}
+	l.debugModeMap.mu.Lock()
+	oldState, ok := l.debugModeMap.debugMode[serviceName[0]]
+	if ok {
+		l.debugModeMap.debugMode[serviceName[0]] = false
+		l.publishDebug()
+	}
+	//only stop the timer if no other service is in debug mode
+	otherDebug := l.anyDebug()
+	if ok && !otherDebug {
+		l.stopDebugTimer(true)
+	}
+	l.debugModeMap.mu.Unlock()
+	if ok {
+		if !otherDebug {
+			l.Info("Debug Mode Disabled")
+		}
+		l.audit(ctx, serviceName[0], oldState, false, time.Time{})
+		l.saveState()
 	}
 }
 
 //SetSystemDebugStatus - function to set if the call is a system call or service call
 func (l *logger) SetSystemDebugStatus(status bool) {
-	//set all individual service status
+	l.SetSystemDebugStatusContext(context.Background(), status)
+}
+
+//SetSystemDebugStatusContext - same as SetSystemDebugStatus, the actor and source of the change are
+// taken from the context
+func (l *logger) SetSystemDebugStatusContext(ctx context.Context, status bool) {
+	var value int32
+	if status {
+		value = 1
+	}
+
 	l.debugModeMap.mu.Lock()
+	//set all individual service status
 	for serviceName := range l.debugModeMap.debugMode {
 		l.debugModeMap.debugMode[serviceName] = status
 	}
-	l.debugModeMap.mu.Unlock()
+	l.publishDebug()
 	//set overall status
-	l.systemDebug = status
-	l.debug <- status
+	oldState := atomic.SwapInt32(&l.systemDebug, value) != 0
+	if status {
+		l.resetDebugTimer()
+	} else {
+		l.stopDebugTimer(true)
+	}
+	expiry := l.debugDeadline
+	l.debugModeMap.mu.Unlock()
+
+	if status {
+		l.Info("Debug Mode Enabled")
+	} else {
+		l.Info("Debug Mode Disabled")
+	}
+	l.audit(ctx, "", oldState, status, expiry)
+	l.saveState()
 }
 
 func (l *logger) GetSystemDebugStatus() bool {
-	return l.systemDebug
+	return atomic.LoadInt32(&l.systemDebug) != 0
 }
 
 func (l *logger) CheckDebugMap(serviceName string) (found bool) {
+	l.debugModeMap.mu.RLock()
 	_, found = l.debugModeMap.debugMode[serviceName]
+	l.debugModeMap.mu.RUnlock()
 
 	return
 }
 
-func (l *logger) LaunchDebug() {
-	started := make(chan struct{})
-	l.Add(1)
-	go l.goDebug(started)
-	<-started
+//publishDebug replaces the snapshot of the services in debug mode read by the logging calls, must be
+// called with the debug map lock held after the debug map changes
+func (l *logger) publishDebug() {
+	enabled := make(map[string]bool)
+	for serviceName, mode := range l.debugModeMap.debugMode {
+		if mode {
+			enabled[serviceName] = true
+		}
+	}
+	l.debugEnabled.Store(enabled)
+	atomic.StoreInt32(&l.debugServices, int32(len(enabled)))
 }
 
-//goDebug - Creates a routine to manage debug time
-func (l *logger) goDebug(started chan struct{}) {
-	defer l.Done()
+//serviceDebug returns whether or not debug is enabled for the service without locking or
+// allocating so that disabled debug entries cost next to nothing
+func (l *logger) serviceDebug(serviceName string) bool {
+	if atomic.LoadInt32(&l.debugServices) == 0 {
+		return false
+	}
+	enabled, _ := l.debugEnabled.Load().(map[string]bool)
 
-	expire := time.NewTicker(ConfigDebugTimer)
-	expire.Stop()
-	defer expire.Stop()
-	close(started)
+	return enabled[serviceName]
+}
 
-	for {
-		select {
-		case <-l.stopper:
-			return
+//getCommonName returns the common component name
+func (l *logger) getCommonName() string {
+	commonName, _ := l.commonName.Load().(string)
 
-		case <-expire.C:
-			expire.Stop()
-			l.Info("Debug Timer Expired")
-			//time expired, reset all services debug mode and overall
-			l.debugModeMap.mu.Lock()
-			for serviceName := range l.debugModeMap.debugMode {
-				l.debugModeMap.debugMode[serviceName] = false
-			}
-			l.debugModeMap.mu.Unlock()
-			l.systemDebug = false
-
-		case debug := <-l.debug:
-			//setting to debug will reset the timer
-			if debug {
-				expire.Stop()
-				expire = time.NewTicker(ConfigDebugTimer)
-				l.Info("Debug Mode Enabled")
-			} else {
-				//only stop the timer if no other service is in debug mode
-				otherDebug := false
-				l.debugModeMap.mu.Lock()
-				for _, mode := range l.debugModeMap.debugMode {
-					if mode {
-						otherDebug = true
-						break
-					}
-				}
-				l.debugModeMap.mu.Unlock()
-				if !otherDebug {
-					expire.Stop()
-					l.Info("Debug Mode Disabled")
-				}
-			}
-		}
+	return commonName
+}
+
+//Applies sampling and deduplication before logging
+func (l *logger) log(serviceName, content string, severity string, fields ...zap.Field) {
+	//entries are counted for the summary even if they aren't written
+	if l.settings().summaryInterval > 0 {
+		l.reporter.record(serviceName, severity, content)
 	}
+	//repeated entries are counted before sampling so that the summaries count every entry, the
+	// summaries themselves aren't sampled
+	flushed, write := l.deduper.check(serviceName, severity, content)
+	if flushed != nil {
+		l.writeRepeated(serviceName, *flushed)
+	}
+	if write && l.sampler.allow(serviceName, severity) {
+		l.write(serviceName, content, severity, fields...)
+	}
+}
+
+//Performs the actual logging operation, the fields are written to the log files and exported
+func (l *logger) write(serviceName, content string, severity string, fields ...zap.Field) {
+	timestamp := time.Now()
+	l.writeLocal(timestamp, serviceName, content, severity, fields...)
+	l.otlp.enqueue(timestamp, serviceName, content, severity, fields)
 }
 
-//Performs the actual logging operation
-func (l *logger) log(serviceName, content string, severity string) {
+//writeLocal writes an entry to stdout and the log files without exporting it
+func (l *logger) writeLocal(timestamp time.Time, serviceName, content string, severity string, fields ...zap.Field) {
 	//Make the entry
 	logentry := LogEntryDocker{Level: severity,
-		Timestamp: time.Now().Unix(),
+		Timestamp: timestamp.Unix(),
 		Name:      serviceName,
 		Content:   content,
 	}
@@ -331,65 +633,67 @@ func (l *logger) log(serviceName, content string, severity string) {
 	//Marshal the struct
 	logjson, _ := json.Marshal(entry)
 	dockerjson, _ := json.Marshal(logentry)
-	//Print the JSON
-	fmt.Println(string(dockerjson))
-	if severity == "DEBUG" {
-		ZapLogger.Debug(string(logjson))
-	} else if severity == "INFO" {
-		ZapLogger.Info(string(logjson))
-	} else if severity == "WARN" {
-		ZapLogger.Warn(string(logjson))
-	} else {
-		ZapLogger.Error(string(logjson))
-
+	//Print the JSON, entries written to encrypted files aren't printed in plaintext
+	if !l.settings().encrypted() {
+		_, err := fmt.Println(string(dockerjson))
+		l.stdoutStats.record(err)
 	}
+	//write to the file the service is routed to
+	l.writeZap(serviceName, func(zapLogger *zap.Logger) {
+		if severity == "DEBUG" {
+			zapLogger.Debug(string(logjson), fields...)
+		} else if severity == "INFO" {
+			zapLogger.Info(string(logjson), fields...)
+		} else if severity == "WARN" {
+			zapLogger.Warn(string(logjson), fields...)
+		} else if severity == "FATAL" {
+			if checked := zapLogger.Check(fatalLevel, string(logjson)); checked != nil {
+				checked.Write(fields...)
+			}
+		} else {
+			zapLogger.Error(string(logjson), fields...)
+
+		}
+	})
 
 }
 
 //Debug
 func (l *logger) Debug(content string) {
-	if l.systemDebug {
-		l.log(l.commonName, content, DEBUG)
+	if atomic.LoadInt32(&l.systemDebug) != 0 {
+		l.log(l.getCommonName(), content, DEBUG)
 	}
 }
 
 //Info
 func (l *logger) Info(content string) {
-	l.log(l.commonName, content, INFO)
+	l.log(l.getCommonName(), content, INFO)
 }
 
 //Warn
 func (l *logger) Warn(content string) {
-	l.log(l.commonName, content, WARN)
+	l.log(l.getCommonName(), content, WARN)
 }
 
 //Error
 func (l *logger) Error(content error) {
-	l.log(l.commonName, content.Error(), ERROR)
+	l.log(l.getCommonName(), content.Error(), ERROR, codeFields(content)...)
 }
 
 //FormatError
 func (l *logger) FormatError(format string, errs ...interface{}) {
-	l.log(l.commonName, fmt.Errorf(format, errs...).Error(), ERROR)
+	l.log(l.getCommonName(), formatError(format, errs...), ERROR, codeFields(errs...)...)
 }
 
 //Fatal
 func (l *logger) Fatal(content string) {
-	l.log(l.commonName, content, FATAL)
+	l.log(l.getCommonName(), content, FATAL)
 }
 
 //DebugService
 func (l *logger) DebugService(serviceName, content string) {
-	if l.systemDebug {
+	if atomic.LoadInt32(&l.systemDebug) != 0 || l.serviceDebug(serviceName) {
 		l.log(serviceName, content, DEBUG)
-	} else {
-		l.debugModeMap.mu.Lock()
-		if mode, ok := l.debugModeMap.debugMode[serviceName]; ok {
-			if mode {
-				l.log(serviceName, content, DEBUG)
-			}
-		}
-		l.debugModeMap.mu.Unlock()
 	}
 }
 
@@ -405,15 +709,37 @@ func (l *logger) WarnService(serviceName, content string) {
 
 //ErrorService
 func (l *logger) ErrorService(serviceName string, content error) {
-	l.log(serviceName, content.Error(), ERROR)
+	l.log(serviceName, content.Error(), ERROR, codeFields(content)...)
 }
 
 //FormatErrorService
 func (l *logger) FormatErrorService(serviceName, format string, errs ...interface{}) {
-	l.log(serviceName, fmt.Errorf(format, errs...).Error(), ERROR)
+	l.log(serviceName, formatError(format, errs...), ERROR, codeFields(errs...)...)
 }
 
 //FatalService
 func (l *logger) FatalService(serviceName, content string) {
 	l.log(serviceName, content, FATAL)
 }
+
+//codeFields returns the code of the first error of the catalog found in the arguments as a field
+func codeFields(errs ...interface{}) []zap.Field {
+	for _, e := range errs {
+		if err, ok := e.(error); ok {
+			if code := catalog.CodeOf(err); code != "" {
+				return []zap.Field{zap.String("code", code)}
+			}
+		}
+	}
+
+	return nil
+}
+
+//formatError formats an error entry, the error itself is only built if the format wraps an error
+func formatError(format string, errs ...interface{}) string {
+	if strings.Contains(format, "%w") {
+		return fmt.Errorf(format, errs...).Error()
+	}
+
+	return fmt.Sprintf(format, errs...)
+}
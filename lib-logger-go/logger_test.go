@@ -0,0 +1,381 @@
+package logger
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+//testTimeout is how long a call may block before the test fails
+const testTimeout = 5 * time.Second
+
+//inTempDir runs the test in a temporary directory so that the log files are removed afterwards
+func inTempDir(t testing.TB) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+}
+
+//newTestLogger returns a configured logger knowing the services
+func newTestLogger(t testing.TB, envs map[string]string, serviceNames ...string) *logger {
+	t.Helper()
+
+	l := NewLogger().(*logger)
+	if err := l.Configure("test", envs); err != nil {
+		t.Fatal(err)
+	}
+	for _, serviceName := range serviceNames {
+		l.UpdateDebugMap(serviceName, false)
+	}
+	t.Cleanup(func() {
+		l.Close()
+	})
+
+	return l
+}
+
+//withTimeout fails the test if the function doesn't return in time
+func withTimeout(t *testing.T, name string, fx func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fx()
+	}()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatalf("%s blocked", name)
+	}
+}
+
+//useLogger calls every method reading or changing the debug state and logging
+func useLogger(l *logger, serviceName string, i int) {
+	switch i % 8 {
+	case 0:
+		l.EnableDebug(serviceName)
+	case 1:
+		l.DisableDebug(serviceName)
+	case 2:
+		l.SetSystemDebugStatus(i%16 == 2)
+	case 3:
+		l.UpdateDebugMap(serviceName, i%16 == 3)
+	}
+	l.IsDebugEnabled(serviceName)
+	l.IsDebugEnabled()
+	l.GetSystemDebugStatus()
+	l.CheckDebugMap(serviceName)
+	l.Debug("debug")
+	l.DebugService(serviceName, "debug")
+	l.InfoService(serviceName, "info")
+	l.FormatErrorService(serviceName, "error %d", i)
+	l.GetDebugTime()
+}
+
+func TestEnableDebugBeforeStart(t *testing.T) {
+	inTempDir(t)
+	l := newTestLogger(t, nil, "service")
+
+	withTimeout(t, "EnableDebug", func() {
+		l.EnableDebug("service")
+		l.SetSystemDebugStatus(true)
+	})
+	if !l.IsDebugEnabled("service") || !l.GetSystemDebugStatus() {
+		t.Fatal("debug not enabled before start")
+	}
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if !l.IsDebugEnabled("service") {
+		t.Error("debug enabled before start lost on start")
+	}
+	l.debugModeMap.mu.RLock()
+	deadline := l.debugDeadline
+	l.debugModeMap.mu.RUnlock()
+	if deadline.IsZero() {
+		t.Error("debug enabled before start doesn't expire")
+	}
+}
+
+func TestConcurrentDebug(t *testing.T) {
+	inTempDir(t)
+	l := newTestLogger(t, nil, "a", "b", "c")
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	//start and stop the logger while it's used
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := l.Stop(); err != nil {
+				t.Error(err)
+			}
+			if err := l.Start(); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	var users sync.WaitGroup
+	for _, serviceName := range []string{"a", "b", "c", "unknown"} {
+		users.Add(1)
+		go func(serviceName string) {
+			defer users.Done()
+			for i := 0; i < 200; i++ {
+				useLogger(l, serviceName, i)
+			}
+		}(serviceName)
+	}
+	withTimeout(t, "concurrent use", users.Wait)
+	close(stop)
+	wg.Wait()
+}
+
+func TestUseAfterStop(t *testing.T) {
+	inTempDir(t)
+	l := newTestLogger(t, nil, "service")
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	withTimeout(t, "use after stop", func() {
+		for i := 0; i < 16; i++ {
+			useLogger(l, "service", i)
+		}
+	})
+	//the logger can be started again and keeps its debug state
+	l.EnableDebug("service")
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if !l.IsDebugEnabled("service") {
+		t.Error("debug state lost on restart")
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	withTimeout(t, "use after close", func() {
+		for i := 0; i < 16; i++ {
+			useLogger(l, "service", i)
+		}
+	})
+	if err := l.Start(); !errors.Is(err, ErrLoggerClosed) {
+		t.Errorf("start after close returned %v", err)
+	}
+	if err := l.Configure("test", nil); !errors.Is(err, ErrLoggerClosed) {
+		t.Errorf("configure after close returned %v", err)
+	}
+}
+
+func TestConfigureWhileLogging(t *testing.T) {
+	inTempDir(t)
+	l := newTestLogger(t, nil, "service")
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				useLogger(l, "service", i)
+				l.Introspect()
+			}
+		}()
+	}
+	//reconfigure the logger with summaries and deduplication while it's used
+	for i := 1; i <= 10; i++ {
+		envs := map[string]string{
+			EnvNameDebugTimer:      strconv.Itoa(i),
+			EnvNameSummaryInterval: strconv.Itoa(i % 2),
+			EnvNameDedupInterval:   strconv.Itoa(i % 3),
+		}
+		if err := l.Configure("test", envs); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Configure("test", envs); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Start(); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Configure("test", envs); !errors.Is(err, ErrLoggerStarted) {
+			t.Errorf("configure while started returned %v", err)
+		}
+		if err := l.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestConfigureFailureKeepsConfiguration(t *testing.T) {
+	inTempDir(t)
+	l := newTestLogger(t, map[string]string{EnvNameDebugTimer: "5"})
+
+	envs := map[string]string{
+		EnvNameDebugTimer:       "7",
+		EnvNameLogEncryptionKey: "invalid",
+	}
+	if err := l.Configure("test", envs); err == nil {
+		t.Fatal("configured with an invalid encryption key")
+	}
+	if debugTime := l.GetDebugTime(); debugTime != 5*time.Minute {
+		t.Errorf("debug time is %s after a failed configuration", debugTime)
+	}
+	configMu.Lock()
+	debugTimer := ConfigDebugTimer
+	configMu.Unlock()
+	if debugTimer != 5*time.Minute {
+		t.Errorf("ConfigDebugTimer is %s after a failed configuration", debugTimer)
+	}
+	if err := l.Start(); err != nil {
+		t.Fatalf("logger unusable after a failed configuration: %s", err)
+	}
+}
+
+func TestStopWithCollectorDown(t *testing.T) {
+	inTempDir(t)
+	//the collector accepts the requests but never answers
+	release := make(chan struct{})
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer collector.Close()
+	defer close(release)
+
+	l := newTestLogger(t, map[string]string{
+		EnvNameOTLPEndpoint: collector.URL,
+		EnvNameOTLPInterval: "1",
+	}, "service")
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < otlpQueueSize; i++ {
+		l.InfoService("service", "info")
+	}
+	//let a tick start draining the queue
+	time.Sleep(1500 * time.Millisecond)
+	withTimeout(t, "stop with the collector down", func() {
+		if err := l.Stop(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestDisabledDebugDoesntAllocate(t *testing.T) {
+	inTempDir(t)
+	l := newTestLogger(t, nil, "service")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Debug("debug")
+		l.DebugService("service", "debug")
+		l.DebugService("unknown", "debug")
+	})
+	if allocs != 0 {
+		t.Errorf("disabled debug allocates %.0f times", allocs)
+	}
+}
+
+//benchmarkLogger returns a started logger with the debug of the service enabled or not
+func benchmarkLogger(b *testing.B, debug bool) *logger {
+	inTempDir(b)
+	l := newTestLogger(b, nil, "service")
+	if err := l.Start(); err != nil {
+		b.Fatal(err)
+	}
+	if debug {
+		l.EnableDebug("service")
+		l.SetSystemDebugStatus(true)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	return l
+}
+
+func BenchmarkDebugDisabled(b *testing.B) {
+	l := benchmarkLogger(b, false)
+	for i := 0; i < b.N; i++ {
+		l.Debug("debug")
+	}
+}
+
+func BenchmarkDebugServiceDisabled(b *testing.B) {
+	l := benchmarkLogger(b, false)
+	for i := 0; i < b.N; i++ {
+		l.DebugService("service", "debug")
+	}
+}
+
+func BenchmarkDebugServiceDisabledParallel(b *testing.B) {
+	l := benchmarkLogger(b, false)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.DebugService("service", "debug")
+		}
+	})
+}
+
+func BenchmarkDebugEnabled(b *testing.B) {
+	l := benchmarkLogger(b, true)
+	for i := 0; i < b.N; i++ {
+		l.Debug("debug")
+	}
+}
+
+func BenchmarkDebugServiceEnabled(b *testing.B) {
+	l := benchmarkLogger(b, true)
+	for i := 0; i < b.N; i++ {
+		l.DebugService("service", "debug")
+	}
+}
+
+func BenchmarkInfoService(b *testing.B) {
+	l := benchmarkLogger(b, false)
+	for i := 0; i < b.N; i++ {
+		l.InfoService("service", "info")
+	}
+}
+
+func BenchmarkFormatErrorService(b *testing.B) {
+	l := benchmarkLogger(b, false)
+	err := errors.New("error")
+	for i := 0; i < b.N; i++ {
+		l.FormatErrorService("service", "failed %d: %v", i, err)
+	}
+}
@@ -0,0 +1,490 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// Exports the entries as OpenTelemetry (OTLP) log records, either over OTLP/HTTP to a collector or
+// to a file in the OTLP JSON format. Records are batched and exported by a routine so that logging
+// never waits on the collector.
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+//otlp constants
+const (
+	OTLPScopeName       string = "lib-logger-go"
+	OTLPLogsPath        string = "/v1/logs"
+	otlpFieldTraceID    string = "trace_id"
+	otlpFieldSpanID     string = "span_id"
+	otlpQueueSize       int    = 4096             //records kept until the next export, later records are dropped
+	otlpBatchSize       int    = 512              //records exported in a single request
+	otlpRequestTimeout         = 10 * time.Second //time a single request may take
+	otlpExportTimeout          = 10 * time.Second //time the export of the queued records may take at each tick
+	otlpStopTimeout            = 2 * time.Second  //time the last export may take when the logger is stopped
+	resourceServiceName string = "service.name"
+	resourceInstanceID  string = "service.instance.id"
+)
+
+//ensure that the exporters implement the Exporter interface
+var (
+	_ Exporter = &httpExporter{}
+	_ Exporter = &fileExporter{}
+)
+
+//Exporter sends batches of log records to a collector
+type Exporter interface {
+	Export(ctx context.Context, request OTLPRequest) error
+	Close() error
+}
+
+//TraceExtractor returns the hex encoded trace and span ids of the context, empty if there are none
+type TraceExtractor func(ctx context.Context) (traceID, spanID string)
+
+//OTLPRequest is the OTLP JSON encoding of an export logs request
+type OTLPRequest struct {
+	ResourceLogs []OTLPResourceLogs `json:"resourceLogs"`
+}
+
+//OTLPResourceLogs holds the records of a resource, i.e. of a service
+type OTLPResourceLogs struct {
+	Resource  OTLPResource    `json:"resource"`
+	ScopeLogs []OTLPScopeLogs `json:"scopeLogs"`
+}
+
+//OTLPResource describes where the records come from
+type OTLPResource struct {
+	Attributes []OTLPKeyValue `json:"attributes"`
+}
+
+//OTLPScopeLogs holds the records written by a library
+type OTLPScopeLogs struct {
+	Scope      OTLPScope       `json:"scope"`
+	LogRecords []OTLPLogRecord `json:"logRecords"`
+}
+
+//OTLPScope is the library that wrote the records
+type OTLPScope struct {
+	Name string `json:"name"`
+}
+
+//OTLPLogRecord is a single entry, 64 bit integers are encoded as strings in OTLP JSON
+type OTLPLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 OTLPAnyValue   `json:"body"`
+	Attributes           []OTLPKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+}
+
+//OTLPKeyValue is an attribute
+type OTLPKeyValue struct {
+	Key   string       `json:"key"`
+	Value OTLPAnyValue `json:"value"`
+}
+
+//OTLPAnyValue is the value of an attribute or the body of a record
+type OTLPAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+//otlpRecord is an entry waiting to be exported
+type otlpRecord struct {
+	serviceName string
+	record      OTLPLogRecord
+}
+
+//otlp batches the records of the logger for its exporter
+type otlp struct {
+	sync.RWMutex
+	exporter  Exporter       //exports the records, nothing is exported if nil
+	extractor TraceExtractor //returns the trace ids of a context
+	instance  string         //instance id written in the resource of every record
+	target    string         //endpoint or file the records are exported to
+	queue     chan otlpRecord
+	dropped   int64     //number of records dropped since the last export, accessed atomically
+	stats     sinkStats //counts the exports
+}
+
+func newOTLP() *otlp {
+	return &otlp{
+		extractor: TraceIDsFromContext,
+		queue:     make(chan otlpRecord, otlpQueueSize),
+	}
+}
+
+//ContextWithTraceIDs returns a context carrying the hex encoded trace and span ids that entries
+// logged with LogContext are attached to
+func ContextWithTraceIDs(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(context.WithValue(ctx, contextKeyTraceID, traceID), contextKeySpanID, spanID)
+}
+
+//TraceIDsFromContext is the default TraceExtractor, it returns the ids set by ContextWithTraceIDs
+func TraceIDsFromContext(ctx context.Context) (traceID, spanID string) {
+	if ctx != nil {
+		traceID, _ = ctx.Value(contextKeyTraceID).(string)
+		spanID, _ = ctx.Value(contextKeySpanID).(string)
+	}
+
+	return
+}
+
+//severityNumber returns the OTLP severity number of a level
+func severityNumber(severity string) int {
+	switch strings.ToUpper(severity) {
+	case "DEBUG":
+		return 5
+	case "INFO":
+		return 9
+	case "WARN":
+		return 13
+	case "ERROR":
+		return 17
+	default:
+		return 21
+	}
+}
+
+//NewHTTPExporter returns an exporter posting OTLP JSON to the endpoint of a collector, the logs path
+// is appended to an endpoint without one
+func NewHTTPExporter(endpoint string) Exporter {
+	if !strings.HasSuffix(endpoint, OTLPLogsPath) {
+		endpoint = strings.TrimSuffix(endpoint, "/") + OTLPLogsPath
+	}
+
+	return &httpExporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: otlpRequestTimeout},
+	}
+}
+
+type httpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (e *httpExporter) Export(ctx context.Context, request OTLPRequest) (err error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	response, err := e.client.Do(httpRequest)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		err = ErrOTLPStatusf.Withf(response.StatusCode)
+	}
+
+	return
+}
+
+func (e *httpExporter) Close() error {
+	e.client.CloseIdleConnections()
+
+	return nil
+}
+
+//NewFileExporter returns an exporter appending every request to a file as a line of OTLP JSON, the
+// format read by the file receiver of the collector
+func NewFileExporter(path string) (Exporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileExporter{file: file}, nil
+}
+
+type fileExporter struct {
+	sync.Mutex
+	file *os.File
+}
+
+func (e *fileExporter) Export(ctx context.Context, request OTLPRequest) (err error) {
+	line, err := json.Marshal(request)
+	if err != nil {
+		return
+	}
+	e.Lock()
+	defer e.Unlock()
+
+	_, err = e.file.Write(append(line, '\n'))
+
+	return
+}
+
+func (e *fileExporter) Close() error {
+	e.Lock()
+	defer e.Unlock()
+
+	return e.file.Close()
+}
+
+//newExporter returns the exporter of the configuration, nil if none is configured
+func newExporter(c *config) (exporter Exporter, err error) {
+	switch {
+	case c.otlpEndpoint != "":
+		exporter = NewHTTPExporter(c.otlpEndpoint)
+	case c.otlpFile != "":
+		exporter, err = NewFileExporter(c.otlpFile)
+	}
+
+	return
+}
+
+//configure sets the instance id written in the resource of the records
+func (o *otlp) configure(instance string) {
+	o.Lock()
+	defer o.Unlock()
+
+	o.instance = instance
+}
+
+//setExporter replaces the exporter, the previous exporter is returned so it can be closed
+func (o *otlp) setExporter(exporter Exporter) (previous Exporter) {
+	o.Lock()
+	defer o.Unlock()
+
+	previous, o.exporter = o.exporter, exporter
+	switch e := exporter.(type) {
+	case *httpExporter:
+		o.target = e.endpoint
+	case *fileExporter:
+		o.target = e.file.Name()
+	default:
+		o.target = ""
+	}
+
+	return
+}
+
+//info describes the exporter, ok is false if there is none
+func (o *otlp) info() (info SinkInfo, ok bool) {
+	o.RLock()
+	ok = o.exporter != nil
+	info = SinkInfo{
+		Type:   SinkTypeOTLP,
+		Target: o.target,
+	}
+	o.RUnlock()
+	o.stats.fill(&info)
+
+	return
+}
+
+//enqueue queues an entry for export, the entry is dropped if the queue is full
+func (o *otlp) enqueue(timestamp time.Time, serviceName, content, severity string, fields []zap.Field) {
+	o.RLock()
+	exporting := o.exporter != nil
+	o.RUnlock()
+	if !exporting {
+		return
+	}
+	timeUnixNano := strconv.FormatInt(timestamp.UnixNano(), 10)
+	record := OTLPLogRecord{
+		TimeUnixNano:         timeUnixNano,
+		ObservedTimeUnixNano: timeUnixNano,
+		SeverityNumber:       severityNumber(severity),
+		SeverityText:         strings.ToUpper(severity),
+		Body:                 OTLPAnyValue{StringValue: content},
+	}
+	for _, field := range fields {
+		if field.Type != zapcore.StringType {
+			continue
+		}
+		switch field.Key {
+		case otlpFieldTraceID:
+			record.TraceID = field.String
+		case otlpFieldSpanID:
+			record.SpanID = field.String
+		default:
+			record.Attributes = append(record.Attributes, OTLPKeyValue{Key: field.Key, Value: OTLPAnyValue{StringValue: field.String}})
+		}
+	}
+	select {
+	case o.queue <- otlpRecord{serviceName: serviceName, record: record}:
+	default:
+		atomic.AddInt64(&o.dropped, 1)
+	}
+}
+
+//request builds the export request of a batch, the records are grouped by service
+func (o *otlp) request(batch []otlpRecord) (request OTLPRequest) {
+	o.RLock()
+	instance := o.instance
+	o.RUnlock()
+
+	index := make(map[string]int)
+	for _, r := range batch {
+		i, ok := index[r.serviceName]
+		if !ok {
+			i = len(request.ResourceLogs)
+			index[r.serviceName] = i
+			request.ResourceLogs = append(request.ResourceLogs, OTLPResourceLogs{
+				Resource: OTLPResource{
+					Attributes: []OTLPKeyValue{
+						{Key: resourceServiceName, Value: OTLPAnyValue{StringValue: r.serviceName}},
+						{Key: resourceInstanceID, Value: OTLPAnyValue{StringValue: instance}},
+					},
+				},
+				ScopeLogs: []OTLPScopeLogs{{Scope: OTLPScope{Name: OTLPScopeName}}},
+			})
+		}
+		scopeLogs := &request.ResourceLogs[i].ScopeLogs[0]
+		scopeLogs.LogRecords = append(scopeLogs.LogRecords, r.record)
+	}
+
+	return
+}
+
+//export exports the queued records in batches until the queue is empty, the context is done or a
+// batch fails, the records left are exported next time and the records of the failed batch are dropped
+func (o *otlp) export(ctx context.Context) (err error) {
+	o.RLock()
+	exporter := o.exporter
+	o.RUnlock()
+
+	for ctx.Err() == nil {
+		var batch []otlpRecord
+	fill:
+		for len(batch) < otlpBatchSize {
+			select {
+			case r := <-o.queue:
+				batch = append(batch, r)
+			default:
+				break fill
+			}
+		}
+		if len(batch) == 0 || exporter == nil {
+			return
+		}
+		requestCtx, cancel := context.WithTimeout(ctx, otlpRequestTimeout)
+		exportErr := exporter.Export(requestCtx, o.request(batch))
+		cancel()
+		o.stats.record(exportErr)
+		if exportErr != nil {
+			atomic.AddInt64(&o.dropped, int64(len(batch)))
+
+			return exportErr
+		}
+	}
+
+	return
+}
+
+//SetExporter replaces the exporter configured from the environment, a nil exporter stops exporting,
+// the previous exporter is closed
+func (l *logger) SetExporter(exporter Exporter) error {
+	if previous := l.otlp.setExporter(exporter); previous != nil {
+		return previous.Close()
+	}
+
+	return nil
+}
+
+//SetTraceExtractor replaces how trace and span ids are read from the context given to LogContext,
+// e.g. to read the span of a tracing library
+func (l *logger) SetTraceExtractor(extractor TraceExtractor) {
+	l.otlp.Lock()
+	defer l.otlp.Unlock()
+
+	if extractor == nil {
+		extractor = TraceIDsFromContext
+	}
+	l.otlp.extractor = extractor
+}
+
+//LogContext logs an entry of a service at the given level (e.g. INFO), the trace and span ids of
+// the context are attached to the entry
+func (l *logger) LogContext(ctx context.Context, severity, serviceName, content string) {
+	if strings.EqualFold(severity, DEBUG) && !l.IsDebugEnabled() && !l.IsDebugEnabled(serviceName) {
+		return
+	}
+	l.otlp.RLock()
+	extractor := l.otlp.extractor
+	l.otlp.RUnlock()
+
+	var fields []zap.Field
+	traceID, spanID := extractor(ctx)
+	if traceID != "" {
+		fields = append(fields, zap.String(otlpFieldTraceID, traceID))
+	}
+	if spanID != "" {
+		fields = append(fields, zap.String(otlpFieldSpanID, spanID))
+	}
+	l.log(serviceName, content, severity, fields...)
+}
+
+//exportQueued exports the queued records within the timeout, failures are only written locally so
+// that they aren't exported themselves
+func (l *logger) exportQueued(ctx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := l.otlp.export(ctx); err != nil {
+		l.writeLocal(time.Now(), l.getCommonName(), ErrOTLPExport.Wrap(err).Error(), ERROR)
+	}
+	if dropped := atomic.SwapInt64(&l.otlp.dropped, 0); dropped > 0 {
+		l.writeLocal(time.Now(), l.getCommonName(), ErrOTLPDroppedf.Withf(dropped).Error(), WARN)
+	}
+}
+
+func (l *logger) LaunchExport() {
+	started := make(chan struct{})
+	l.Add(1)
+	go l.goExport(started)
+	<-started
+}
+
+//goExport - Creates a routine to periodically export the queued records
+func (l *logger) goExport(started chan struct{}) {
+	defer l.Done()
+
+	//the exports are aborted when the logger is stopped so that stopping doesn't wait on the collector
+	stopper := l.stopper
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopper:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	export := time.NewTicker(l.settings().otlpInterval)
+	defer export.Stop()
+	close(started)
+
+	for {
+		select {
+		case <-stopper:
+			l.exportQueued(context.Background(), otlpStopTimeout)
+			return
+
+		case <-export.C:
+			l.exportQueued(ctx, otlpExportTimeout)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//testExporter counts the exported records, the first exports fail
+type testExporter struct {
+	failures int
+	requests int
+	records  int
+}
+
+func (e *testExporter) Export(ctx context.Context, request OTLPRequest) error {
+	e.requests++
+	if e.requests <= e.failures {
+		return errors.New("collector down")
+	}
+	for _, resourceLogs := range request.ResourceLogs {
+		for _, scopeLogs := range resourceLogs.ScopeLogs {
+			e.records += len(scopeLogs.LogRecords)
+		}
+	}
+
+	return nil
+}
+
+func (e *testExporter) Close() error {
+	return nil
+}
+
+func TestExportStopsOnFailure(t *testing.T) {
+	const records = 2*otlpBatchSize + 1
+	exporter := &testExporter{failures: 1}
+	o := newOTLP()
+	o.setExporter(exporter)
+	for i := 0; i < records; i++ {
+		o.enqueue(time.Now(), "service", "entry", INFO, nil)
+	}
+
+	//the records after the failed batch stay queued
+	if err := o.export(context.Background()); err == nil {
+		t.Fatal("failed export returned no error")
+	}
+	if exporter.requests != 1 || len(o.queue) != records-otlpBatchSize {
+		t.Errorf("failed export sent %d requests and left %d records", exporter.requests, len(o.queue))
+	}
+	if dropped := atomic.LoadInt64(&o.dropped); dropped != int64(otlpBatchSize) {
+		t.Errorf("%d records of the failed batch dropped", dropped)
+	}
+	if err := o.export(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if exporter.records != records-otlpBatchSize || len(o.queue) != 0 {
+		t.Errorf("exported %d records and left %d records", exporter.records, len(o.queue))
+	}
+}
@@ -0,0 +1,110 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// Reads back the entries written to the log files, used by the tools working on the log files so
+// that they don't break whenever the format shifts
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//timestampFormat is the format of zap's ISO8601 time encoder
+const timestampFormat = "2006-01-02T15:04:05.000Z0700"
+
+//levels in increasing order of severity as written by zap
+var levels = map[string]int{
+	"debug": 0,
+	"info":  1,
+	"warn":  2,
+	"error": 3,
+	"fatal": 4,
+}
+
+//Record is an entry read back from a log file
+type Record struct {
+	Level     string    `json:"level"`
+	Timestamp time.Time `json:"timestamp"`
+	Name      string    `json:"name"`
+	Content   string    `json:"content"`
+	Raw       []byte    `json:"-"` //line as read from the file
+}
+
+//zapRecord is the layout of a line written by zap, the message holds the json LogEntry
+type zapRecord struct {
+	Level     string `json:"level"`
+	Timestamp string `json:"timestamp"`
+	Message   string `json:"msg"`
+}
+
+//Query filters records, empty fields match every record
+type Query struct {
+	Services []string       //service names to match
+	Level    string         //minimum level to match
+	Since    time.Time      //match records at or after
+	Until    time.Time      //match records before
+	Pattern  *regexp.Regexp //match records whose content matches
+}
+
+//ParseRecord parses a line of a log file, lines that aren't log entries (e.g. the header of a
+// chained file) return an error
+func ParseRecord(line []byte) (record Record, err error) {
+	var raw zapRecord
+	if err = json.Unmarshal(line, &raw); err != nil {
+		return
+	}
+	if raw.Level == "" || raw.Message == "" {
+		err = ErrRecordNotEntry
+
+		return
+	}
+	var entry LogEntry
+	if json.Unmarshal([]byte(raw.Message), &entry) != nil {
+		//entries not written through the logger carry a plain message
+		entry.Content = raw.Message
+	}
+	record = Record{
+		Level:   strings.ToLower(raw.Level),
+		Name:    entry.Name,
+		Content: entry.Content,
+		Raw:     line,
+	}
+	if record.Timestamp, err = time.Parse(timestampFormat, raw.Timestamp); err != nil {
+		record.Timestamp, err = time.Parse(time.RFC3339Nano, raw.Timestamp)
+	}
+
+	return
+}
+
+//Match returns whether or not the record matches the query
+func (q Query) Match(record Record) bool {
+	if len(q.Services) > 0 {
+		found := false
+		for _, serviceName := range q.Services {
+			if serviceName == record.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.Level != "" && levels[record.Level] < levels[strings.ToLower(q.Level)] {
+		return false
+	}
+	if !q.Since.IsZero() && record.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !record.Timestamp.Before(q.Until) {
+		return false
+	}
+	if q.Pattern != nil && !q.Pattern.MatchString(record.Content) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRecord(t *testing.T) {
+	record, err := ParseRecord([]byte(`{"level":"warn","timestamp":"2024-03-01T10:00:00.000Z","msg":"{\"name\":\"a\",\"content\":\"disk low\"}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Level != "warn" || record.Name != "a" || record.Content != "disk low" || !record.Timestamp.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("entry parsed as %+v", record)
+	}
+	//entries not written through the logger keep their message as content
+	record, err = ParseRecord([]byte(`{"level":"INFO","timestamp":"2024-03-01T10:00:00.5+01:00","msg":"plain message"}`))
+	if err != nil || record.Level != "info" || record.Name != "" || record.Content != "plain message" || record.Timestamp.Nanosecond() != 5e8 {
+		t.Errorf("plain entry parsed as %+v, %v", record, err)
+	}
+	for name, line := range map[string]string{
+		"header":    `{"chain":"header","version":1}`,
+		"no level":  `{"timestamp":"2024-03-01T10:00:00.000Z","msg":"message"}`,
+		"not json":  `not json`,
+		"timestamp": `{"level":"info","timestamp":"yesterday","msg":"message"}`,
+	} {
+		if _, err = ParseRecord([]byte(line)); err == nil {
+			t.Errorf("%s line parsed", name)
+		}
+	}
+	if _, err = ParseRecord([]byte(`{"chain":"header"}`)); !errors.Is(err, ErrRecordNotEntry) {
+		t.Errorf("header parsed with %v", err)
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	now := time.Now()
+	record := Record{Level: "error", Timestamp: now, Name: "a", Content: "connection refused"}
+
+	for name, test := range map[string]struct {
+		query Query
+		match bool
+	}{
+		"empty":            {Query{}, true},
+		"service":          {Query{Services: []string{"b", "a"}}, true},
+		"other service":    {Query{Services: []string{"b"}}, false},
+		"lower level":      {Query{Level: "warn"}, true},
+		"same level":       {Query{Level: "ERROR"}, true},
+		"higher level":     {Query{Level: "fatal"}, false},
+		"since":            {Query{Since: now}, true},
+		"since later":      {Query{Since: now.Add(time.Second)}, false},
+		"until":            {Query{Until: now}, false},
+		"until later":      {Query{Until: now.Add(time.Second)}, true},
+		"pattern":          {Query{Pattern: regexp.MustCompile("refused$")}, true},
+		"other pattern":    {Query{Pattern: regexp.MustCompile("timeout")}, false},
+		"every filter":     {Query{Services: []string{"a"}, Level: "info", Since: now.Add(-time.Second), Until: now.Add(time.Second), Pattern: regexp.MustCompile("connection")}, true},
+		"one filter fails": {Query{Services: []string{"a"}, Level: "info", Pattern: regexp.MustCompile("timeout")}, false},
+	} {
+		if match := test.query.Match(record); match != test.match {
+			t.Errorf("%s query matched %v", name, match)
+		}
+	}
+}
+
+func TestQueryLoggedLevels(t *testing.T) {
+	inTempDir(t)
+
+	l := newTestLogger(t, nil, "a")
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	l.InfoService("a", "started")
+	l.ErrorService("a", errors.New("failed"))
+	l.FatalService("a", "crashed")
+	l.Fatal("common crashed")
+	if err := l.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	//the levels written by the logger are the levels queried
+	matched := make(map[string][]string)
+	for _, line := range strings.Split(strings.TrimSpace(readLog(t, "test.log")), "\n") {
+		record, err := ParseRecord([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, level := range []string{"info", "error", "fatal"} {
+			if (Query{Level: level}).Match(record) {
+				matched[level] = append(matched[level], record.Content)
+			}
+		}
+	}
+	if fatal := matched["fatal"]; len(fatal) != 2 || fatal[0] != "crashed" || fatal[1] != "common crashed" {
+		t.Errorf("fatal query matched %v", fatal)
+	}
+	if len(matched["error"]) != 3 || len(matched["info"]) < 4 {
+		t.Errorf("queries matched %v", matched)
+	}
+}
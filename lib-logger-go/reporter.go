@@ -0,0 +1,200 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// Periodic summary of the entries logged by each service so that a site can be monitored through a
+// compact heartbeat instead of the raw logs
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//maxTrackedMessages limits the distinct messages counted per service between two summaries
+const maxTrackedMessages int = 1000
+
+//Publisher publishes a message to a topic, the broker connector satisfies it
+type Publisher interface {
+	Publish(TopicName string, message interface{})
+}
+
+//MessageCount is a message and the number of times it was logged
+type MessageCount struct {
+	Content string `json:"content"`
+	Count   int    `json:"count"`
+}
+
+//ServiceSummary describes the entries of a service logged during a summary interval
+type ServiceSummary struct {
+	Levels      map[string]int `json:"levels"`      //number of entries per level
+	Total       int            `json:"total"`       //number of entries
+	ErrorRate   float64        `json:"errorRate"`   //share of the entries that are errors or fatal
+	TopMessages []MessageCount `json:"topMessages"` //most frequent messages, most frequent first
+}
+
+//Summary is the report emitted at the end of every summary interval
+type Summary struct {
+	Start    time.Time                 `json:"start"`
+	End      time.Time                 `json:"end"`
+	Services map[string]ServiceSummary `json:"services"`
+}
+
+type summaryEntry struct {
+	Summary Summary `json:"summary"`
+}
+
+//serviceCounts accumulates the entries of a service
+type serviceCounts struct {
+	levels   map[string]int
+	messages map[string]int
+}
+
+//reporter counts the entries logged by each service until the next summary
+type reporter struct {
+	sync.Mutex
+	start     time.Time
+	services  map[string]*serviceCounts
+	publisher Publisher //optional publisher of the summaries
+	topicName string    //topic the summaries are published to
+}
+
+func newReporter() *reporter {
+	return &reporter{
+		start:    time.Now(),
+		services: make(map[string]*serviceCounts),
+	}
+}
+
+//reset drops the counts, the next summary starts now
+func (r *reporter) reset() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.start = time.Now()
+	r.services = make(map[string]*serviceCounts)
+}
+
+//setPublisher sets the publisher of the summaries, nil stops publishing
+func (r *reporter) setPublisher(publisher Publisher, topicName string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.publisher, r.topicName = publisher, topicName
+}
+
+//record counts an entry of a service
+func (r *reporter) record(serviceName, severity, content string) {
+	r.Lock()
+	defer r.Unlock()
+
+	counts, ok := r.services[serviceName]
+	if !ok {
+		counts = &serviceCounts{
+			levels:   make(map[string]int),
+			messages: make(map[string]int),
+		}
+		r.services[serviceName] = counts
+	}
+	counts.levels[strings.ToUpper(severity)]++
+	//once the limit is reached only the messages already tracked are counted
+	if _, ok := counts.messages[content]; ok || len(counts.messages) < maxTrackedMessages {
+		counts.messages[content]++
+	}
+}
+
+//summarize returns the summary of the counted entries and starts a new interval
+func (r *reporter) summarize(topN int) (summary Summary, publisher Publisher, topicName string) {
+	r.Lock()
+	defer r.Unlock()
+
+	summary = Summary{
+		Start:    r.start,
+		End:      time.Now(),
+		Services: make(map[string]ServiceSummary),
+	}
+	for serviceName, counts := range r.services {
+		serviceSummary := ServiceSummary{
+			Levels: counts.levels,
+		}
+		for _, count := range counts.levels {
+			serviceSummary.Total += count
+		}
+		if serviceSummary.Total > 0 {
+			errorCount := counts.levels[strings.ToUpper(ERROR)] + counts.levels[strings.ToUpper(FATAL)]
+			serviceSummary.ErrorRate = float64(errorCount) / float64(serviceSummary.Total)
+		}
+		serviceSummary.TopMessages = topMessages(counts.messages, topN)
+		summary.Services[serviceName] = serviceSummary
+	}
+	r.start = summary.End
+	r.services = make(map[string]*serviceCounts)
+
+	return summary, r.publisher, r.topicName
+}
+
+//topMessages returns the n most frequent messages, ties are ordered by content
+func topMessages(messages map[string]int, n int) (top []MessageCount) {
+	top = make([]MessageCount, 0, len(messages))
+	for content, count := range messages {
+		top = append(top, MessageCount{Content: content, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Content < top[j].Content
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+
+	return
+}
+
+//SetSummaryPublisher publishes the periodic summaries to the topic in addition to logging them,
+// a nil publisher stops publishing
+func (l *logger) SetSummaryPublisher(publisher Publisher, topicName string) {
+	l.reporter.setPublisher(publisher, topicName)
+}
+
+//emitSummary logs the summary of the last interval and publishes it if a publisher is set, the
+// summary itself isn't counted
+func (l *logger) emitSummary() {
+	summary, publisher, topicName := l.reporter.summarize(l.settings().summaryTopN)
+	if bytes, err := json.Marshal(summaryEntry{Summary: summary}); err == nil {
+		l.write(l.getCommonName(), string(bytes), INFO)
+	}
+	if publisher != nil {
+		publisher.Publish(topicName, summary)
+	}
+}
+
+func (l *logger) LaunchSummary() {
+	started := make(chan struct{})
+	l.Add(1)
+	go l.goSummary(started)
+	<-started
+}
+
+//goSummary - Creates a routine to periodically emit the summary
+func (l *logger) goSummary(started chan struct{}) {
+	defer l.Done()
+
+	summary := time.NewTicker(l.settings().summaryInterval)
+	defer summary.Stop()
+	close(started)
+
+	for {
+		select {
+		case <-l.stopper:
+			l.emitSummary()
+			return
+
+		case <-summary.C:
+			l.emitSummary()
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//testPublisher records the published summaries
+type testPublisher struct {
+	sync.Mutex
+	topics    []string
+	summaries []Summary
+}
+
+func (p *testPublisher) Publish(topicName string, message interface{}) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.topics = append(p.topics, topicName)
+	p.summaries = append(p.summaries, message.(Summary))
+}
+
+func TestSummarize(t *testing.T) {
+	r := newReporter()
+	start := r.start
+	for i := 0; i < 3; i++ {
+		r.record("a", INFO, "polled")
+	}
+	r.record("a", "info", "connected")
+	r.record("a", ERROR, "timeout")
+	r.record("a", FATAL, "crashed")
+	r.record("a", WARN, "slow")
+	r.record("a", DEBUG, "tick")
+	r.record("b", DEBUG, "tick")
+
+	summary, _, _ := r.summarize(2)
+	if !summary.Start.Equal(start) || summary.End.Before(summary.Start) || len(summary.Services) != 2 {
+		t.Fatalf("summarized as %+v", summary)
+	}
+	a := summary.Services["a"]
+	if a.Total != 8 || a.Levels["INFO"] != 4 || a.Levels["ERROR"] != 1 || a.Levels["FATAL"] != 1 || a.Levels["WARN"] != 1 || a.Levels["DEBUG"] != 1 {
+		t.Errorf("levels summarized as %+v", a)
+	}
+	//errors and fatal entries are counted as errors
+	if a.ErrorRate != 0.25 {
+		t.Errorf("error rate summarized as %v", a.ErrorRate)
+	}
+	//the most frequent messages first, ties ordered by content
+	if len(a.TopMessages) != 2 || a.TopMessages[0] != (MessageCount{"polled", 3}) || a.TopMessages[1] != (MessageCount{"connected", 1}) {
+		t.Errorf("top messages summarized as %+v", a.TopMessages)
+	}
+	if b := summary.Services["b"]; b.Total != 1 || b.ErrorRate != 0 || len(b.TopMessages) != 1 {
+		t.Errorf("b summarized as %+v", b)
+	}
+	//the next summary starts at the end of the previous one without its counts
+	next, _, _ := r.summarize(2)
+	if !next.Start.Equal(summary.End) || len(next.Services) != 0 {
+		t.Errorf("next summary is %+v", next)
+	}
+	if top := topMessages(map[string]int{"a": 1}, 0); len(top) != 0 {
+		t.Errorf("no message summarized as %v", top)
+	}
+}
+
+func TestSummaryMessageLimit(t *testing.T) {
+	r := newReporter()
+	for i := 0; i < maxTrackedMessages; i++ {
+		r.record("a", INFO, fmt.Sprint("message ", i))
+	}
+	//once the limit is reached new messages aren't tracked but are still counted
+	r.record("a", INFO, "untracked")
+	r.record("a", INFO, "message 0")
+
+	summary, _, _ := r.summarize(1)
+	a := summary.Services["a"]
+	if a.Total != maxTrackedMessages+2 {
+		t.Errorf("%d entries summarized", a.Total)
+	}
+	if len(a.TopMessages) != 1 || a.TopMessages[0] != (MessageCount{"message 0", 2}) {
+		t.Errorf("top messages summarized as %+v", a.TopMessages)
+	}
+}
+
+func TestEmitSummary(t *testing.T) {
+	inTempDir(t)
+
+	l := newTestLogger(t, map[string]string{EnvNameSummaryInterval: "3600", EnvNameSummaryTopN: "1"}, "a")
+	publisher := &testPublisher{}
+	l.SetSummaryPublisher(publisher, "summaries")
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	//sampled entries are counted even if they aren't written
+	l.ConfigureSampling(SamplingRule{ServiceName: "a", Tick: time.Hour})
+	l.InfoService("a", "dropped")
+	l.InfoService("a", "dropped")
+	l.ErrorService("a", fmt.Errorf("failed"))
+	//the summary is emitted when the logger stops
+	if err := l.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(publisher.summaries) != 1 || publisher.topics[0] != "summaries" {
+		t.Fatalf("published %d summaries to %v", len(publisher.summaries), publisher.topics)
+	}
+	a := publisher.summaries[0].Services["a"]
+	if a.Total != 3 || len(a.TopMessages) != 1 || a.TopMessages[0] != (MessageCount{"dropped", 2}) {
+		t.Errorf("published summary is %+v", a)
+	}
+	var logged struct {
+		Summary Summary `json:"summary"`
+	}
+	for _, line := range strings.Split(strings.TrimSpace(readLog(t, "test.log")), "\n") {
+		if record, err := ParseRecord([]byte(line)); err == nil && strings.Contains(record.Content, `"summary"`) {
+			if err = json.Unmarshal([]byte(record.Content), &logged); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if logged.Summary.Services["a"].Total != 3 {
+		t.Errorf("logged summary is %+v", logged.Summary)
+	}
+	//the summary itself isn't counted
+	if summary, _, _ := l.reporter.summarize(1); len(summary.Services) != 0 {
+		t.Errorf("summary counted as %+v", summary.Services)
+	}
+	//without publisher the summaries are only logged
+	l.SetSummaryPublisher(nil, "")
+	l.InfoService("a", "after")
+	l.emitSummary()
+	if len(publisher.summaries) != 1 {
+		t.Errorf("published %d summaries without publisher", len(publisher.summaries))
+	}
+}
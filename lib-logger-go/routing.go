@@ -0,0 +1,202 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// Routes the entries of services to their own rotated log files so that a chatty service doesn't
+// drown the history of the others
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"io"
+	"path/filepath"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+//Rotation defines how a log file is rotated and how long backups are retained, zero values fall
+// back to the values of DefaultRotation
+type Rotation struct {
+	MaxSize    int  `json:"maxSize"`    //maximum size in megabytes before the file is rotated
+	MaxBackups int  `json:"maxBackups"` //number of backups to keep
+	MaxAge     int  `json:"maxAge"`     //days to keep backups
+	Compress   bool `json:"compress"`   //whether or not backups are compressed
+}
+
+//LogRoute routes the entries of a group of services to their own file, a route without services
+// is the catch-all for every service that isn't routed
+type LogRoute struct {
+	Filename string   //name of the file, relative to the log directory
+	Services []string //service names written to the file
+	Rotation Rotation //rotation and retention of the file
+}
+
+//DefaultRotation is the rotation of the catch-all log file
+var DefaultRotation = Rotation{
+	MaxSize:    10,
+	MaxBackups: 10,
+	MaxAge:     28,
+	Compress:   false,
+}
+
+//withDefaults returns a copy of the rotation with the zero values replaced by the defaults
+func (r Rotation) withDefaults() Rotation {
+	if r.MaxSize <= 0 {
+		r.MaxSize = DefaultRotation.MaxSize
+	}
+	if r.MaxBackups <= 0 {
+		r.MaxBackups = DefaultRotation.MaxBackups
+	}
+	if r.MaxAge <= 0 {
+		r.MaxAge = DefaultRotation.MaxAge
+	}
+
+	return r
+}
+
+//ValidateLogRoutes can be used to confirm that a slice of provided routes has no common errors, each
+// route must have its own file since two routes rotating the same file would corrupt it
+func ValidateLogRoutes(routes []LogRoute) (err error) {
+	var serviceMap = make(map[string]struct{})
+	var filenameMap = make(map[string]struct{})
+	var empty struct{}
+	var catchAll bool
+
+	for _, route := range routes {
+		//check if the filename is empty
+		if route.Filename == "" {
+			err = ErrRouteFilenameEmpty
+
+			return
+		}
+		//check that a file is only used by a single route
+		filename := filepath.Clean(route.Filename)
+		if _, ok := filenameMap[filename]; ok {
+			err = ErrRouteFilenameTwicef.Withf(route.Filename)
+
+			return
+		}
+		filenameMap[filename] = empty
+		//check that there's only a single catch-all
+		if len(route.Services) == 0 {
+			if catchAll {
+				err = ErrRouteDefaultTwice
+
+				return
+			}
+			catchAll = true
+		}
+		//check that a service is only routed to a single file
+		for _, serviceName := range route.Services {
+			if _, ok := serviceMap[serviceName]; ok {
+				err = ErrRouteDuplicatef.Withf(serviceName)
+
+				return
+			}
+			serviceMap[serviceName] = empty
+		}
+	}
+
+	return
+}
+
+//ConfigureRoutes replaces the log routes of the logger, services that aren't routed are written to
+// the catch-all route or to <commonName>.log if there isn't one
+func (l *logger) ConfigureRoutes(routes ...LogRoute) (err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if err = l.checkConfigured(); err != nil {
+		return
+	}
+	if err = ValidateLogRoutes(routes); err != nil {
+		return
+	}
+	//<commonName>.log is the fallback of the catch-all, a route rotating it as well would corrupt it
+	commonFile := filepath.Clean(l.logDir + l.getCommonName() + ".log")
+	for _, route := range routes {
+		if filepath.Join(l.logDir, route.Filename) == commonFile {
+			err = ErrRouteFilenameCommonf.Withf(route.Filename)
+
+			return
+		}
+	}
+	routedLoggers := make(map[string]*zap.Logger)
+	var routedClosers []io.Closer
+	var catchAll *zap.Logger
+	var catchAllCloser io.Closer
+	for _, route := range routes {
+		zapLogger, closer, zapErr := newZapLogger(filepath.Join(l.logDir, route.Filename), route.Rotation.withDefaults(), l.settings())
+		if zapErr != nil {
+			//close the files opened for the previous routes, the current routes are kept
+			if catchAllCloser != nil {
+				routedClosers = append(routedClosers, catchAllCloser)
+			}
+			closeRouted(routedLoggers, routedClosers)
+			err = zapErr
+
+			return
+		}
+		if len(route.Services) == 0 {
+			catchAll, catchAllCloser = zapLogger, closer
+		} else {
+			routedClosers = append(routedClosers, closer)
+		}
+		for _, serviceName := range route.Services {
+			routedLoggers[serviceName] = zapLogger
+		}
+	}
+	//fall back to <commonName>.log, only re-creating it if a catch-all route replaced it before
+	catchAllRouted := catchAll != nil
+	if catchAll == nil && l.catchAllRouted {
+		if catchAll, catchAllCloser, err = newZapLogger(l.logDir+l.getCommonName()+".log", DefaultRotation, l.settings()); err != nil {
+			closeRouted(routedLoggers, routedClosers)
+
+			return
+		}
+	}
+	//swap the loggers, flushing and closing the previous ones so nothing buffered is lost
+	l.routesMu.Lock()
+	previous, previousClosers := l.routedLoggers, l.routedClosers
+	l.routedLoggers, l.routedClosers, l.routes = routedLoggers, routedClosers, routes
+	if catchAll != nil {
+		CloseLogging()
+		ZapLogger, zapCloser = catchAll, catchAllCloser
+	}
+	l.catchAllRouted = catchAllRouted
+	l.routesMu.Unlock()
+	closeRouted(previous, previousClosers)
+
+	return
+}
+
+//closeRouted flushes the routed loggers and closes their files
+func closeRouted(routedLoggers map[string]*zap.Logger, routedClosers []io.Closer) (err error) {
+	for _, zapLogger := range routedLoggers {
+		zapLogger.Sync()
+	}
+	for _, closer := range routedClosers {
+		if closeErr := closer.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+
+	return
+}
+
+//writeZap writes with the logger of the file the service is routed to, the lock is held during the
+// write so that the file can't be closed while it's written, the files are closed with the logger
+func (l *logger) writeZap(serviceName string, write func(zapLogger *zap.Logger)) {
+	l.routesMu.RLock()
+	defer l.routesMu.RUnlock()
+
+	if atomic.LoadInt32(&l.state) == stateClosed {
+		return
+	}
+	zapLogger, ok := l.routedLoggers[serviceName]
+	if !ok {
+		zapLogger = ZapLogger
+	}
+	if zapLogger != nil {
+		write(zapLogger)
+	}
+}
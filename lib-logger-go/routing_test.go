@@ -0,0 +1,143 @@
+package logger
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestValidateLogRoutes(t *testing.T) {
+	for name, test := range map[string]struct {
+		routes []LogRoute
+		err    error
+	}{
+		"valid": {[]LogRoute{
+			{Filename: "a.log", Services: []string{"a", "b"}},
+			{Filename: "c.log", Services: []string{"c"}},
+			{Filename: "rest.log"},
+		}, nil},
+		"none":              {nil, nil},
+		"empty filename":    {[]LogRoute{{Services: []string{"a"}}}, ErrRouteFilenameEmpty},
+		"filename twice":    {[]LogRoute{{Filename: "a.log", Services: []string{"a"}}, {Filename: "./a.log", Services: []string{"b"}}}, ErrRouteFilenameTwicef},
+		"catch-all twice":   {[]LogRoute{{Filename: "a.log"}, {Filename: "b.log"}}, ErrRouteDefaultTwice},
+		"service twice":     {[]LogRoute{{Filename: "a.log", Services: []string{"a"}}, {Filename: "b.log", Services: []string{"b", "a"}}}, ErrRouteDuplicatef},
+		"service in a file": {[]LogRoute{{Filename: "a.log", Services: []string{"a", "a"}}}, ErrRouteDuplicatef},
+	} {
+		if err := ValidateLogRoutes(test.routes); !errors.Is(err, test.err) || (test.err == nil) != (err == nil) {
+			t.Errorf("%s routes validated with %v", name, err)
+		}
+	}
+}
+
+//readLog returns the content of a log file
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestConfigureRoutes(t *testing.T) {
+	inTempDir(t)
+
+	l := NewLogger().(*logger)
+	if err := l.ConfigureRoutes(LogRoute{Filename: "a.log"}); !errors.Is(err, ErrLoggerNotConfigured) {
+		t.Errorf("routes of a new logger configured with %v", err)
+	}
+	l = newTestLogger(t, nil)
+	if err := l.ConfigureRoutes(LogRoute{Filename: "test.log", Services: []string{"a"}}); !errors.Is(err, ErrRouteFilenameCommonf) {
+		t.Errorf("route to the common file configured with %v", err)
+	}
+	if err := l.ConfigureRoutes(LogRoute{Filename: "a.log"}, LogRoute{Filename: "b.log"}); !errors.Is(err, ErrRouteDefaultTwice) {
+		t.Errorf("invalid routes configured with %v", err)
+	}
+	if err := l.ConfigureRoutes(LogRoute{Filename: "a.log", Services: []string{"a"}}, LogRoute{Filename: "rest.log"}); err != nil {
+		t.Fatal(err)
+	}
+	l.InfoService("a", "routed entry")
+	l.InfoService("b", "catch-all entry")
+	//without catch-all route the entries of the services that aren't routed go back to the common file
+	if err := l.ConfigureRoutes(LogRoute{Filename: "a.log", Services: []string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+	l.InfoService("a", "second routed entry")
+	l.InfoService("b", "common entry")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string][]string{
+		"a.log":    {"routed entry", "second routed entry"},
+		"rest.log": {"catch-all entry"},
+		"test.log": {"common entry"},
+	} {
+		content := readLog(t, path)
+		if count := strings.Count(content, "\n"); count != len(want) {
+			t.Errorf("%s holds %d entries: %s", path, count, content)
+		}
+		for _, entry := range want {
+			if !strings.Contains(content, entry) {
+				t.Errorf("%s doesn't hold %q: %s", path, entry, content)
+			}
+		}
+	}
+}
+
+func TestConfigureRoutesWhileLogging(t *testing.T) {
+	inTempDir(t)
+
+	key := newTestEncryptionKey(t, "key")
+	l := newTestLogger(t, map[string]string{
+		EnvNameLogEncryptionKey: key.ID + ":" + base64.StdEncoding.EncodeToString(key.Key),
+	})
+	route := LogRoute{Filename: "a.log", Services: []string{"a"}}
+	if err := l.ConfigureRoutes(route); err != nil {
+		t.Fatal(err)
+	}
+	//entries written while the files are replaced aren't lost
+	var wg sync.WaitGroup
+	var written int64
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				l.InfoService("a", "entry "+strconv.Itoa(i)+" "+strconv.Itoa(j))
+				atomic.AddInt64(&written, 1)
+			}
+		}(i)
+	}
+	for i := 0; i < 50; i++ {
+		if err := l.ConfigureRoutes(route); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := decryptFile("a.log", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := strings.Count(plain, "entry "); int64(count) != written {
+		t.Errorf("%d entries written out of %d", count, written)
+	}
+}
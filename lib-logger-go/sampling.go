@@ -0,0 +1,253 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// Sampling and deduplication of log entries so that a flapping service can't flood the log files
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//sampleTick is the default tick of the sampling rules, the expired counters are removed as often
+const sampleTick = time.Second
+
+//SamplingRule limits how many entries of a service and level are written each tick, the first
+// entries are always written and after that only every Thereafter-th entry
+type SamplingRule struct {
+	ServiceName string        //service the rule applies to, empty for every service
+	Level       string        //level the rule applies to (e.g. WARN), empty for every level
+	First       int           //entries written each tick before sampling starts
+	Thereafter  int           //write every Thereafter-th entry once First is reached, 0 drops them
+	Tick        time.Duration //how often the counters are reset, defaults to a second
+}
+
+type sampleCounter struct {
+	reset time.Time
+	tick  time.Duration
+	count int
+}
+
+//sampler applies the sampling rules, the most specific rule of a service and level wins
+type sampler struct {
+	sync.Mutex
+	rules    map[string]SamplingRule
+	counters map[string]*sampleCounter
+	pruned   time.Time //when the expired counters were last removed
+}
+
+//repeat tracks the last entry of a service to collapse identical consecutive entries
+type repeat struct {
+	severity string
+	content  string
+	count    int
+}
+
+//deduper collapses identical consecutive entries of a service into a periodic repeated entry
+type deduper struct {
+	sync.Mutex
+	interval time.Duration
+	last     map[string]*repeat
+}
+
+//ensure the key of a rule can't clash with a service name
+func sampleKey(serviceName, severity string) string {
+	return serviceName + "\x00" + strings.ToUpper(severity)
+}
+
+func newSampler() *sampler {
+	return &sampler{
+		rules:    make(map[string]SamplingRule),
+		counters: make(map[string]*sampleCounter),
+	}
+}
+
+//configure replaces the rules and resets the counters
+func (s *sampler) configure(rules []SamplingRule) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.rules = make(map[string]SamplingRule)
+	s.counters = make(map[string]*sampleCounter)
+	for _, rule := range rules {
+		if rule.Tick <= 0 {
+			rule.Tick = sampleTick
+		}
+		s.rules[sampleKey(rule.ServiceName, rule.Level)] = rule
+	}
+}
+
+//list returns the rules sorted by service and level
+func (s *sampler) list() (rules []SamplingRule) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, rule := range s.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].ServiceName != rules[j].ServiceName {
+			return rules[i].ServiceName < rules[j].ServiceName
+		}
+		return rules[i].Level < rules[j].Level
+	})
+
+	return
+}
+
+//rule returns the most specific rule for the service and level
+func (s *sampler) rule(serviceName, severity string) (rule SamplingRule, key string, ok bool) {
+	for _, key = range []string{
+		sampleKey(serviceName, severity),
+		sampleKey(serviceName, ""),
+		sampleKey("", severity),
+		sampleKey("", ""),
+	} {
+		if rule, ok = s.rules[key]; ok {
+			return
+		}
+	}
+
+	return
+}
+
+//allow returns whether or not an entry of the service and level should be written
+func (s *sampler) allow(serviceName, severity string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.rules) == 0 {
+		return true
+	}
+	rule, key, ok := s.rule(serviceName, severity)
+	if !ok {
+		return true
+	}
+	//counters are kept per service and level even if the rule is shared
+	key = sampleKey(serviceName, severity) + "\x00" + key
+	now := time.Now()
+	s.prune(now)
+	counter, ok := s.counters[key]
+	if !ok || now.Sub(counter.reset) >= counter.tick {
+		counter = &sampleCounter{reset: now, tick: rule.Tick}
+		s.counters[key] = counter
+	}
+	counter.count++
+	if counter.count <= rule.First {
+		return true
+	}
+
+	return rule.Thereafter > 0 && (counter.count-rule.First)%rule.Thereafter == 0
+}
+
+//prune removes the counters whose tick has elapsed so that counters of services that stopped logging
+// aren't kept, must be called with the lock held
+func (s *sampler) prune(now time.Time) {
+	if now.Sub(s.pruned) < sampleTick {
+		return
+	}
+	s.pruned = now
+	for key, counter := range s.counters {
+		if now.Sub(counter.reset) >= counter.tick {
+			delete(s.counters, key)
+		}
+	}
+}
+
+func newDeduper() *deduper {
+	return &deduper{
+		last: make(map[string]*repeat),
+	}
+}
+
+//configure sets the interval repeated entries are written at, 0 disables deduplication
+func (d *deduper) configure(interval time.Duration) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.interval = interval
+	d.last = make(map[string]*repeat)
+}
+
+//check returns whether or not the entry should be written, if the entry ends a run of repeated
+// entries the repeated entry that must be written first is returned as well
+func (d *deduper) check(serviceName, severity, content string) (flushed *repeat, write bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.interval <= 0 {
+		return nil, true
+	}
+	last, ok := d.last[serviceName]
+	if ok && last.severity == severity && last.content == content {
+		last.count++
+
+		return nil, false
+	}
+	if ok && last.count > 0 {
+		flushed = &repeat{severity: last.severity, content: last.content, count: last.count}
+	}
+	d.last[serviceName] = &repeat{severity: severity, content: content}
+
+	return flushed, true
+}
+
+//flush returns the pending repeated entries of every service and resets their count
+func (d *deduper) flush() (flushed map[string]repeat) {
+	d.Lock()
+	defer d.Unlock()
+
+	flushed = make(map[string]repeat)
+	for serviceName, last := range d.last {
+		if last.count > 0 {
+			flushed[serviceName] = *last
+			last.count = 0
+		}
+	}
+
+	return
+}
+
+//ConfigureSampling replaces the sampling rules of the logger
+func (l *logger) ConfigureSampling(rules ...SamplingRule) {
+	l.sampler.configure(rules)
+}
+
+//writeRepeated writes the entry summarizing a run of repeated entries
+func (l *logger) writeRepeated(serviceName string, r repeat) {
+	l.write(serviceName, fmt.Sprintf(InfoMessageRepeatedf, r.count, r.content), r.severity)
+}
+
+func (l *logger) LaunchDedup() {
+	started := make(chan struct{})
+	l.Add(1)
+	go l.goDedup(started)
+	<-started
+}
+
+//goDedup - Creates a routine to periodically write the repeated entries
+func (l *logger) goDedup(started chan struct{}) {
+	defer l.Done()
+
+	flush := time.NewTicker(l.settings().dedupInterval)
+	defer flush.Stop()
+	close(started)
+
+	for {
+		select {
+		case <-l.stopper:
+			for serviceName, r := range l.deduper.flush() {
+				l.writeRepeated(serviceName, r)
+			}
+			return
+
+		case <-flush.C:
+			for serviceName, r := range l.deduper.flush() {
+				l.writeRepeated(serviceName, r)
+			}
+		}
+	}
+}
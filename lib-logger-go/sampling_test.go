@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+//allowed returns how many of the entries of the service and level the sampler writes
+func allowed(s *sampler, serviceName, severity string, entries int) (count int) {
+	for i := 0; i < entries; i++ {
+		if s.allow(serviceName, severity) {
+			count++
+		}
+	}
+
+	return
+}
+
+func TestSamplingRates(t *testing.T) {
+	for name, test := range map[string]struct {
+		rule    SamplingRule
+		allowed int
+	}{
+		"first":      {SamplingRule{First: 3, Tick: time.Hour}, 3},
+		"thereafter": {SamplingRule{First: 2, Thereafter: 4, Tick: time.Hour}, 2 + 4},
+		"every":      {SamplingRule{Thereafter: 1, Tick: time.Hour}, 18},
+		"drop":       {SamplingRule{Tick: time.Hour}, 0},
+	} {
+		s := newSampler()
+		s.configure([]SamplingRule{test.rule})
+		if count := allowed(s, "service", INFO, 18); count != test.allowed {
+			t.Errorf("%s rule allowed %d entries out of 18", name, count)
+		}
+	}
+	//without rules everything is written
+	if count := allowed(newSampler(), "service", INFO, 10); count != 10 {
+		t.Errorf("sampler without rules allowed %d entries out of 10", count)
+	}
+}
+
+func TestSamplingRules(t *testing.T) {
+	s := newSampler()
+	s.configure([]SamplingRule{
+		{ServiceName: "a", Level: "info", First: 1, Tick: time.Hour},
+		{ServiceName: "a", First: 2, Tick: time.Hour},
+		{Level: WARN, First: 3, Tick: time.Hour},
+		{First: 4, Tick: time.Hour},
+	})
+
+	//the most specific rule wins, empty services and levels match everything
+	for _, test := range []struct {
+		serviceName, severity string
+		allowed               int
+	}{
+		{"a", INFO, 1},
+		{"a", WARN, 2},
+		{"b", WARN, 3},
+		{"b", INFO, 4},
+		{"c", ERROR, 4},
+	} {
+		if count := allowed(s, test.serviceName, test.severity, 10); count != test.allowed {
+			t.Errorf("%s %s entries allowed %d times", test.serviceName, test.severity, count)
+		}
+	}
+	//services sharing a rule are counted separately
+	if count := allowed(s, "c", INFO, 10); count != 4 {
+		t.Errorf("service sharing a rule allowed %d times", count)
+	}
+	if rules := s.list(); len(rules) != 4 || rules[0].ServiceName != "" || rules[3].ServiceName != "a" {
+		t.Errorf("rules listed as %v", rules)
+	}
+	//configuring resets the counters
+	s.configure([]SamplingRule{{First: 1, Tick: time.Hour}})
+	if count := allowed(s, "a", INFO, 10); count != 1 {
+		t.Errorf("reconfigured rule allowed %d times", count)
+	}
+}
+
+func TestSamplingTick(t *testing.T) {
+	s := newSampler()
+	s.configure([]SamplingRule{{First: 1, Tick: 50 * time.Millisecond}})
+
+	if count := allowed(s, "service", INFO, 5); count != 1 {
+		t.Errorf("allowed %d times in a tick", count)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if count := allowed(s, "service", INFO, 5); count != 1 {
+		t.Errorf("allowed %d times in the next tick", count)
+	}
+	//expired counters are removed
+	for i := 0; i < 100; i++ {
+		s.allow(fmt.Sprint("service", i), INFO)
+	}
+	s.Lock()
+	s.pruned = time.Time{}
+	s.prune(time.Now().Add(time.Minute))
+	counters := len(s.counters)
+	s.Unlock()
+	if counters != 0 {
+		t.Errorf("%d expired counters kept", counters)
+	}
+}
+
+func TestDedupBeforeSampling(t *testing.T) {
+	inTempDir(t)
+
+	l := newTestLogger(t, map[string]string{EnvNameDedupInterval: "3600"}, "service")
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	l.ConfigureSampling(SamplingRule{First: 1, Tick: time.Hour})
+	for i := 0; i < 5; i++ {
+		l.InfoService("service", "flapping")
+	}
+	l.InfoService("service", "recovered")
+	if err := l.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile("test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//every repeated entry is counted even if the sampler drops the entries
+	if !strings.Contains(string(content), fmt.Sprintf(InfoMessageRepeatedf, 4, "flapping")) {
+		t.Errorf("repeated entries not counted: %s", content)
+	}
+	if strings.Contains(string(content), "recovered") {
+		t.Errorf("sampled entry written: %s", content)
+	}
+}
@@ -0,0 +1,304 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// fileSink writes entries to a lumberjack file and controls its rotation so that every file can be
+// given a header and a footer, entries are hash chained and then encrypted if configured, the file
+// written before a restart is continued rather than rotated
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+)
+
+//footerReserve is the space kept free at the end of a file for its footer
+const footerReserve int64 = 1024
+
+//openSinks are the sinks writing to each file, a sink created for a file that's already written
+// closes the previous sink and continues its chain so that two sinks never write the same file, the
+// entries still written to the previous sink are written by the new one
+var (
+	openSinksMu sync.Mutex
+	openSinks   = make(map[string]*fileSink)
+)
+
+type fileSink struct {
+	sync.Mutex
+	file    *lumberjack.Logger
+	path    string       //cleaned name of the file
+	maxSize int64        //size in bytes at which the file is rotated
+	size    int64        //size in bytes of the current file
+	lines   int64        //number of lines of the current file, entries are encrypted with their line number
+	chain   *hashChain   //chains the entries if not nil
+	cipher  *entryCipher //encrypts the entries if not nil
+	started bool         //whether or not the headers of the current file or segment have been written
+	closed  bool         //whether or not the sink has been closed
+	next    *fileSink    //sink that replaced this one, if any
+}
+
+//newFileSink creates a sink for the lumberjack file, an existing file is continued if it's written
+// the same way, i.e. encrypted with the same key and chained or not, otherwise it's rotated so that
+// every file written by the sink starts with its headers, the chain continues the chain of the last
+// file written, the keys decrypt that file
+func newFileSink(file *lumberjack.Logger, chain *hashChain, cipher *entryCipher, keys []EncryptionKey) *fileSink {
+	openSinksMu.Lock()
+	defer openSinksMu.Unlock()
+
+	path := filepath.Clean(file.Filename)
+	s := &fileSink{
+		file:    file,
+		path:    path,
+		maxSize: int64(file.MaxSize) * 1024 * 1024,
+		chain:   chain,
+		cipher:  cipher,
+	}
+	//the entries forwarded by the previous sink wait for the file to be resumed
+	s.Lock()
+	defer s.Unlock()
+
+	if previous, ok := openSinks[path]; ok {
+		previous.replace(s)
+	}
+	if err := s.resume(keys); err != nil {
+		log.Println(ErrChainResumef.Withf(file.Filename).Wrap(err))
+	}
+	openSinks[path] = s
+
+	return s
+}
+
+//resume continues the current file if it can be continued, otherwise it's rotated, the chain
+// continues the chain of the last file written and a new chain is started if it can't be verified,
+// in which case the file is rotated as well
+func (s *fileSink) resume(keys []EncryptionKey) (err error) {
+	size, lines, continued := s.continues(keys)
+	if s.chain != nil {
+		var result ChainResult
+
+		//a file that isn't continued is sealed
+		if result, err = s.chain.resume(s.file.Filename, keys, !continued); err != nil {
+			continued = false
+		}
+		s.started = continued && !result.Sealed
+	} else {
+		s.started = continued
+	}
+	if continued {
+		s.size, s.lines = size, lines
+
+		return
+	}
+	if size > 0 {
+		s.file.Rotate()
+	}
+
+	return
+}
+
+//continues returns the size and the number of lines of the current file and whether or not it can
+// be continued, i.e. it ends with a complete line, it has entries encrypted with the key of the sink,
+// or isn't encrypted if the sink doesn't encrypt, and it's chained if the sink chains entries
+func (s *fileSink) continues(keys []EncryptionKey) (size int64, lines int64, ok bool) {
+	var entry []byte
+
+	info, err := os.Stat(s.file.Filename)
+	if err != nil || info.Size() == 0 {
+		return
+	}
+	size = info.Size()
+	file, err := os.Open(s.file.Filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	decrypter := NewLineDecrypter(keys)
+	for entry == nil {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil {
+			return
+		}
+		lines++
+		plain, isEntry, decryptErr := decrypter.Decrypt(bytes.TrimSpace(line))
+		if decryptErr != nil {
+			return
+		}
+		if isEntry && len(plain) > 0 {
+			entry = plain
+		}
+	}
+	cipherID := ""
+	if s.cipher != nil {
+		cipherID = s.cipher.key.ID
+	}
+	var headerEntry chainHeaderEntry
+	chained := json.Unmarshal(entry, &headerEntry) == nil && !headerEntry.Header.Started.IsZero()
+	if decrypter.keyID != cipherID || chained != (s.chain != nil) {
+		return
+	}
+	//count the remaining lines, a line left incomplete by a crash can't be continued
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil {
+			ok = len(line) == 0
+
+			return
+		}
+		lines++
+	}
+}
+
+//Write writes a single entry, rotating the file before lumberjack would
+func (s *fileSink) Write(p []byte) (n int, err error) {
+	s.Lock()
+	//the file belongs to the sink that replaced this one
+	if s.closed {
+		next := s.next
+		s.Unlock()
+		if next == nil {
+			return 0, os.ErrClosed
+		}
+
+		return next.Write(p)
+	}
+	defer s.Unlock()
+
+	if s.size > 0 && s.size+int64(s.entrySize(len(p)))+footerReserve > s.maxSize {
+		if err = s.rotate(); err != nil {
+			return
+		}
+	}
+	if !s.started {
+		if err = s.writeHeader(); err != nil {
+			return
+		}
+	}
+	entry := p
+	if s.chain != nil {
+		entry = s.chain.sealEntry(p)
+	}
+	if err = s.write(entry); err != nil {
+		return
+	}
+	n = len(p)
+
+	return
+}
+
+//Close writes the footer and closes the file
+func (s *fileSink) Close() (err error) {
+	err = s.close()
+	openSinksMu.Lock()
+	if openSinks[s.path] == s {
+		delete(openSinks, s.path)
+	}
+	openSinksMu.Unlock()
+
+	return
+}
+
+//replace closes the sink, the entries written to it from now on are written by the next sink
+func (s *fileSink) replace(next *fileSink) {
+	s.Lock()
+	s.next = next
+	s.Unlock()
+	s.close()
+}
+
+//close writes the footer and closes the file without releasing it
+func (s *fileSink) close() (err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if err = s.writeFooter(); err != nil {
+		return
+	}
+	s.size, s.lines, s.started = 0, 0, false
+
+	return s.file.Close()
+}
+
+//entrySize returns the size an entry of the given size will have in the file
+func (s *fileSink) entrySize(size int) int {
+	if s.chain != nil {
+		size += chainFieldSize
+	}
+	if s.cipher != nil {
+		size = s.cipher.encryptedSize(size)
+	}
+
+	return size
+}
+
+//write encrypts an entry if configured and writes it to the file
+func (s *fileSink) write(p []byte) (err error) {
+	if s.cipher != nil {
+		if p, err = s.cipher.encrypt(p, s.lines+1); err != nil {
+			return
+		}
+	}
+
+	return s.writeRaw(p)
+}
+
+func (s *fileSink) writeRaw(p []byte) (err error) {
+	n, err := s.file.Write(p)
+	s.size += int64(n)
+	if n > 0 {
+		s.lines++
+	}
+
+	return
+}
+
+//writeHeader starts a file, or a segment of a file continued after a restart which already has the
+// encryption header
+func (s *fileSink) writeHeader() (err error) {
+	if s.cipher != nil && s.size == 0 {
+		if err = s.writeRaw(s.cipher.header()); err != nil {
+			return
+		}
+	}
+	if s.chain != nil {
+		if err = s.write(s.chain.header()); err != nil {
+			return
+		}
+	}
+	s.started = true
+
+	return
+}
+
+//writeFooter seals the current file or segment if it has been started
+func (s *fileSink) writeFooter() (err error) {
+	if s.chain != nil && s.started {
+		err = s.write(s.chain.footer())
+	}
+
+	return
+}
+
+//rotate closes the current file with its footer and starts a new one
+func (s *fileSink) rotate() (err error) {
+	if err = s.writeFooter(); err != nil {
+		return
+	}
+	if err = s.file.Rotate(); err != nil {
+		return
+	}
+	s.size, s.lines, s.started = 0, 0, false
+
+	return
+}
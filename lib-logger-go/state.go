@@ -0,0 +1,164 @@
+package logger
+
+//---------------------------------------------------------------------------------------------------
+// Persists the debug state of the logger so that a debug session survives a restart of the service
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//ensure that fileStateStore implements the StateStore interface
+var (
+	_ StateStore = &fileStateStore{}
+)
+
+//State describes the debug state of the logger that is persisted between restarts
+type State struct {
+	SystemDebug   bool            `json:"systemDebug"`   //whether debug is enabled for everything
+	Services      map[string]bool `json:"services"`      //debug mode of each known service
+	DebugDeadline time.Time       `json:"debugDeadline"` //when the current debug session expires
+}
+
+//StateStore interface
+type StateStore interface {
+	Load() (state State, err error)
+	Save(state State) (err error)
+}
+
+type fileStateStore struct {
+	sync.Mutex
+	path string
+}
+
+//NewFileStateStore returns a StateStore that keeps the debug state in a local json file
+func NewFileStateStore(path string) StateStore {
+	return &fileStateStore{
+		path: path,
+	}
+}
+
+//Load reads the state from the file, a missing file returns an empty state
+func (f *fileStateStore) Load() (state State, err error) {
+	f.Lock()
+	defer f.Unlock()
+
+	bytes, err := os.ReadFile(f.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+
+		return
+	}
+	err = json.Unmarshal(bytes, &state)
+
+	return
+}
+
+//Save writes the state to a temporary file and renames it so the file is never left half written
+func (f *fileStateStore) Save(state State) (err error) {
+	f.Lock()
+	defer f.Unlock()
+
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	if dir := filepath.Dir(f.path); dir != "" {
+		if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+			return
+		}
+	}
+	tmp := f.path + ".tmp"
+	if err = os.WriteFile(tmp, bytes, 0644); err != nil {
+		return
+	}
+	err = os.Rename(tmp, f.path)
+
+	return
+}
+
+//restoreState loads the persisted state, services are always restored so they are known to the
+// debug map but their debug mode is only kept if the deadline hasn't passed yet, an expired state
+// is saved cleared so that it isn't expired again on every start
+func (l *logger) restoreState() {
+	l.stateMu.Lock()
+	stateStore := l.stateStore
+	l.stateMu.Unlock()
+	if stateStore == nil {
+		return
+	}
+	state, err := stateStore.Load()
+	if err != nil {
+		l.FormatError(InfoErrLoadState, err)
+		return
+	}
+	var enabled []string
+
+	active := state.DebugDeadline.After(time.Now())
+	systemDebug := state.SystemDebug && active
+	expired := state.SystemDebug && !active
+	l.debugModeMap.mu.Lock()
+	for serviceName, mode := range state.Services {
+		l.debugModeMap.debugMode[serviceName] = mode && active
+		if mode && active {
+			enabled = append(enabled, serviceName)
+		}
+		expired = expired || (mode && !active)
+	}
+	l.publishDebug()
+	if systemDebug {
+		atomic.StoreInt32(&l.systemDebug, 1)
+	}
+	restored := systemDebug || len(enabled) > 0
+	if restored {
+		l.debugDeadline = state.DebugDeadline
+	}
+	l.debugModeMap.mu.Unlock()
+
+	ctx := WithActor(WithSource(context.Background(), AuditSourceRestore), l.getCommonName())
+	for _, serviceName := range enabled {
+		l.audit(ctx, serviceName, false, true, state.DebugDeadline)
+	}
+	if systemDebug {
+		l.audit(ctx, "", false, true, state.DebugDeadline)
+	}
+	if restored {
+		l.Info("Debug Mode Restored")
+	}
+	if expired {
+		l.saveState()
+	}
+}
+
+//saveState persists the current debug state if a state store is configured, saves are serialized
+// so that an older state can't overwrite a newer one
+func (l *logger) saveState() {
+	l.stateMu.Lock()
+	defer l.stateMu.Unlock()
+
+	if l.stateStore == nil {
+		return
+	}
+	state := State{
+		Services: make(map[string]bool),
+	}
+	l.debugModeMap.mu.RLock()
+	state.SystemDebug = atomic.LoadInt32(&l.systemDebug) != 0
+	state.DebugDeadline = l.debugDeadline
+	for serviceName, mode := range l.debugModeMap.debugMode {
+		state.Services[serviceName] = mode
+	}
+	l.debugModeMap.mu.RUnlock()
+	if err := l.stateStore.Save(state); err != nil {
+		l.FormatError(InfoErrSaveState, err)
+	}
+}
@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//startWithState starts a logger restoring the state of the file
+func startWithState(t *testing.T, path string) *logger {
+	t.Helper()
+
+	l := newTestLogger(t, map[string]string{EnvNameStateFile: path})
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+func TestFileStateStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "debug.json")
+	store := NewFileStateStore(path)
+
+	//a missing file is an empty state
+	if state, err := store.Load(); err != nil || state.SystemDebug || len(state.Services) != 0 {
+		t.Errorf("missing state loaded as %+v, %v", state, err)
+	}
+	saved := State{
+		SystemDebug:   true,
+		Services:      map[string]bool{"a": true, "b": false},
+		DebugDeadline: time.Now().Add(time.Hour).Round(0),
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	state, err := store.Load()
+	if err != nil || !state.SystemDebug || !state.Services["a"] || state.Services["b"] || !state.DebugDeadline.Equal(saved.DebugDeadline) {
+		t.Errorf("state loaded as %+v, %v", state, err)
+	}
+	//the state is written to a temporary file renamed over the state
+	if _, err = os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+	//a state that can't be written leaves the previous state intact
+	if err = os.Mkdir(path+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Save(State{}); err == nil {
+		t.Error("state saved without its temporary file")
+	}
+	if state, err = store.Load(); err != nil || !state.SystemDebug {
+		t.Errorf("state loaded as %+v after a failed save, %v", state, err)
+	}
+	//a corrupt file isn't loaded
+	if err = os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Load(); err == nil {
+		t.Error("corrupt state loaded")
+	}
+}
+
+func TestRestoreState(t *testing.T) {
+	inTempDir(t)
+
+	deadline := time.Now().Add(time.Hour)
+	NewFileStateStore("state.json").Save(State{
+		SystemDebug:   true,
+		Services:      map[string]bool{"a": true, "b": false},
+		DebugDeadline: deadline,
+	})
+	l := startWithState(t, "state.json")
+
+	if !l.GetSystemDebugStatus() || !l.IsDebugEnabled("a") || l.IsDebugEnabled("b") || !l.CheckDebugMap("b") {
+		t.Error("debug state not restored")
+	}
+	events := l.GetAuditHistory(AuditFilter{Source: AuditSourceRestore})
+	if len(events) != 2 || events[0].ServiceName != "a" || events[1].ServiceName != "" || !events[0].Expiry.Equal(deadline) {
+		t.Errorf("restore audited as %+v", events)
+	}
+}
+
+func TestRestoreExpiredState(t *testing.T) {
+	inTempDir(t)
+
+	NewFileStateStore("state.json").Save(State{
+		SystemDebug:   true,
+		Services:      map[string]bool{"a": true},
+		DebugDeadline: time.Now().Add(-time.Minute),
+	})
+	l := startWithState(t, "state.json")
+
+	//services are known but debug isn't enabled
+	if l.GetSystemDebugStatus() || l.IsDebugEnabled("a") || !l.CheckDebugMap("a") {
+		t.Error("expired debug state restored")
+	}
+	if events := l.GetAuditHistory(AuditFilter{Source: AuditSourceRestore}); len(events) != 0 {
+		t.Errorf("expired state audited as %+v", events)
+	}
+	//the expired state is saved cleared
+	state, err := NewFileStateStore("state.json").Load()
+	if err != nil || state.SystemDebug || state.Services["a"] {
+		t.Errorf("expired state saved as %+v, %v", state, err)
+	}
+	if _, ok := state.Services["a"]; !ok {
+		t.Errorf("expired state saved without its services: %+v", state)
+	}
+}
+
+func TestRestoreCorruptState(t *testing.T) {
+	inTempDir(t)
+
+	if err := os.WriteFile("state.json", []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l := startWithState(t, "state.json")
+
+	if l.GetSystemDebugStatus() {
+		t.Error("debug enabled from a corrupt state")
+	}
+	//changes are saved over the corrupt state
+	l.UpdateDebugMap("a", false)
+	l.EnableDebug("a")
+	if state, err := NewFileStateStore("state.json").Load(); err != nil || !state.Services["a"] {
+		t.Errorf("state saved as %+v, %v", state, err)
+	}
+}
@@ -3,15 +3,42 @@ package logger
 import (
 	"time"
 
+	catalog "github.com/nationaloilwellvarco/max-edge/lib-errors-go/catalog"
 	meta "github.com/nationaloilwellvarco/max-edge/lib-meta-go/meta"
 )
 
-//error constants
-const (
-	ErrServiceNotFoundf  string = meta.ErrServiceNotFoundf
-	ErrCastMetaDebug     string = "unable to cast into meta debug"
-	ErrMetaDebugNotFound string = "meta debug not found"
-	ErrCastRawMessage    string = "unable to cast into json raw message"
+//errors returned by the logger, see the error catalog
+var (
+	ErrServiceNotFoundf      = catalog.New("LOG-001", catalog.CategoryNotFound, catalog.SeverityWarn, meta.ErrServiceNotFoundf)
+	ErrCastMetaDebug         = catalog.New("LOG-002", catalog.CategoryInternal, catalog.SeverityError, "unable to cast into meta debug")
+	ErrMetaDebugNotFound     = catalog.New("LOG-003", catalog.CategoryNotFound, catalog.SeverityWarn, "meta debug not found")
+	ErrCastRawMessage        = catalog.New("LOG-004", catalog.CategoryInternal, catalog.SeverityError, "unable to cast into json raw message")
+	ErrRouteFilenameEmpty    = catalog.New("LOG-005", catalog.CategoryValidation, catalog.SeverityError, "log route filename is empty")
+	ErrRouteDuplicatef       = catalog.New("LOG-006", catalog.CategoryValidation, catalog.SeverityError, "service \"%s\" is routed to more than one log file")
+	ErrRouteDefaultTwice     = catalog.New("LOG-007", catalog.CategoryValidation, catalog.SeverityError, "more than one catch-all log route")
+	ErrLoggerNotConfigured   = catalog.New("LOG-008", catalog.CategoryConflict, catalog.SeverityError, "logger not configured")
+	ErrLoggerStarted         = catalog.New("LOG-009", catalog.CategoryConflict, catalog.SeverityError, "logger already started")
+	ErrLoggerClosed          = catalog.New("LOG-010", catalog.CategoryConflict, catalog.SeverityError, "logger closed")
+	ErrChainEntryMalformed   = catalog.New("LOG-011", catalog.CategoryValidation, catalog.SeverityError, "entry is not chained")
+	ErrChainHashMismatch     = catalog.New("LOG-012", catalog.CategoryValidation, catalog.SeverityError, "hash doesn't match the previous entry")
+	ErrChainBrokenf          = catalog.New("LOG-013", catalog.CategoryValidation, catalog.SeverityError, "hash chain broken at line %d")
+	ErrChainAfterFooterf     = catalog.New("LOG-014", catalog.CategoryValidation, catalog.SeverityError, "entry found after the footer at line %d")
+	ErrChainFooterMismatch   = catalog.New("LOG-015", catalog.CategoryValidation, catalog.SeverityError, "footer doesn't match the entries of the file")
+	ErrChainSignature        = catalog.New("LOG-016", catalog.CategoryValidation, catalog.SeverityError, "footer signature is invalid")
+	ErrChainKeyType          = catalog.New("LOG-017", catalog.CategoryValidation, catalog.SeverityError, "key is not an ed25519 PEM key")
+	ErrEncryptionKeyFormat   = catalog.New("LOG-018", catalog.CategoryValidation, catalog.SeverityError, "encryption key must be of the form <id>:<base64 key>")
+	ErrEncryptionKeyNotFound = catalog.New("LOG-019", catalog.CategoryNotFound, catalog.SeverityError, "no encryption key found")
+	ErrEncryptionKeyIDf      = catalog.New("LOG-020", catalog.CategoryNotFound, catalog.SeverityError, "no encryption key with id \"%s\"")
+	ErrEncryptionHeader      = catalog.New("LOG-021", catalog.CategoryValidation, catalog.SeverityError, "file is not an encrypted log file")
+	ErrDecryptf              = catalog.New("LOG-022", catalog.CategoryValidation, catalog.SeverityError, "unable to decrypt line %d")
+	ErrRecordNotEntry        = catalog.New("LOG-023", catalog.CategoryValidation, catalog.SeverityInfo, "line is not a log entry")
+	ErrOTLPStatusf           = catalog.New("LOG-024", catalog.CategoryUnavailable, catalog.SeverityError, "collector responded with status %d")
+	ErrOTLPExport            = catalog.New("LOG-025", catalog.CategoryUnavailable, catalog.SeverityError, "unable to export log records")
+	ErrOTLPDroppedf          = catalog.New("LOG-026", catalog.CategoryUnavailable, catalog.SeverityWarn, "%d log records dropped before export")
+	ErrRouteFilenameTwicef   = catalog.New("LOG-027", catalog.CategoryValidation, catalog.SeverityError, "log file \"%s\" is used by more than one log route")
+	ErrRouteFilenameCommonf  = catalog.New("LOG-028", catalog.CategoryValidation, catalog.SeverityError, "log file \"%s\" is the common log file")
+	ErrChainKeyMissing       = catalog.New("LOG-029", catalog.CategoryValidation, catalog.SeverityError, "hash chained log files require a signing key")
+	ErrChainResumef          = catalog.New("LOG-030", catalog.CategoryValidation, catalog.SeverityWarn, "unable to resume the hash chain of \"%s\", a new chain is started")
 )
 
 // LogEntry : Message format for API call
@@ -30,17 +57,47 @@ type LogEntryDocker struct {
 
 //Debug env var
 const (
-	EnvNameDebugTimer string = "debugtimer"
+	EnvNameDebugTimer           string = "debugtimer"
+	EnvNameStateFile            string = "statefile"
+	EnvNameAuditHistory         string = "audithistory"
+	EnvNameDedupInterval        string = "dedupinterval"
+	EnvNameLogChain             string = "logchain"
+	EnvNameLogChainKey          string = "logchainkey"
+	EnvNameLogEncryptionKey     string = "logencryptionkey"
+	EnvNameLogEncryptionKeyFile string = "logencryptionkeyfile"
+	EnvNameSummaryInterval      string = "summaryinterval"
+	EnvNameSummaryTopN          string = "summarytopn"
+	EnvNameOTLPEndpoint         string = "otlpendpoint"
+	EnvNameOTLPFile             string = "otlpfile"
+	EnvNameOTLPInstance         string = "otlpinstance"
+	EnvNameOTLPInterval         string = "otlpinterval"
 )
 
 //default configuration constants
 const (
-	DefaultDebugTimer time.Duration = 10 * time.Minute
+	DefaultDebugTimer      time.Duration = 10 * time.Minute
+	DefaultAuditHistory    int           = 1000
+	DefaultDedupInterval   time.Duration = 0 //deduplication is disabled by default
+	DefaultSummaryInterval time.Duration = 0 //summaries are disabled by default
+	DefaultSummaryTopN     int           = 5
+	DefaultOTLPInterval    time.Duration = 5 * time.Second
 )
 
 //configuration variables
 var (
-	ConfigDebugTimer time.Duration = DefaultDebugTimer
+	ConfigDebugTimer           time.Duration = DefaultDebugTimer
+	ConfigAuditHistory         int           = DefaultAuditHistory
+	ConfigDedupInterval        time.Duration = DefaultDedupInterval
+	ConfigLogChain             bool          = false //whether or not the entries of the log files are hash chained
+	ConfigLogChainKey          string        = ""    //PEM file of the ed25519 key signing the footers, required by the chain
+	ConfigLogEncryptionKey     string        = ""    //<id>:<base64 key> used to encrypt the log files
+	ConfigLogEncryptionKeyFile string        = ""    //file holding the keys, the first one is used to encrypt
+	ConfigSummaryInterval      time.Duration = DefaultSummaryInterval
+	ConfigSummaryTopN          int           = DefaultSummaryTopN //number of most frequent messages in a summary
+	ConfigOTLPEndpoint         string        = ""                 //OTLP/HTTP endpoint of the collector the entries are exported to
+	ConfigOTLPFile             string        = ""                 //OTLP JSON file the entries are exported to if there is no endpoint
+	ConfigOTLPInstance         string        = ""                 //instance id of the records, defaults to the hostname
+	ConfigOTLPInterval         time.Duration = DefaultOTLPInterval
 )
 
 //Defines the severity (level) strings
@@ -56,6 +113,9 @@ const (
 const (
 	InfoErrRetrieveDebug string = "Error encountered while retrieving debug \"%s\""
 	InfoErrUpdateDebug   string = "Error encountered while updating debug \"%s\""
+	InfoErrLoadState     string = "Error encountered while loading debug state \"%s\""
+	InfoErrSaveState     string = "Error encountered while saving debug state \"%s\""
+	InfoMessageRepeatedf string = "message repeated %d times: %s"
 )
 
 //DebugJSON defines the payload that must be sent when enabling or disabling debug mode
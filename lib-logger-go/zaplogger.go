@@ -12,6 +12,7 @@ import (
 
 var (
 	ZapLogger *zap.Logger
+	zapCloser io.Closer //closes the file ZapLogger writes to
 )
 
 type WriteSyncer struct {
@@ -22,23 +23,69 @@ func (ws WriteSyncer) Sync() error {
 	return nil
 }
 
-func InitLogging(logName string) {
+//InitLogging replaces ZapLogger with a logger writing to the file, ZapLogger is kept if the file
+// can't be written as configured, e.g. if its encryption key is invalid or its hash chain can't be
+// signed
+func InitLogging(logName string) (err error) {
+	zapLogger, closer, err := newZapLogger(logName, DefaultRotation, currentConfig())
+	if err != nil {
+		return
+	}
+	CloseLogging()
+	ZapLogger, zapCloser = zapLogger, closer
+
+	return
+}
+
+//CloseLogging flushes ZapLogger and closes the file it writes to
+func CloseLogging() (err error) {
+	if ZapLogger != nil {
+		ZapLogger.Sync()
+	}
+	if zapCloser != nil {
+		err = zapCloser.Close()
+		zapCloser = nil
+	}
+
+	return
+}
+
+//fatalLevel is the zap level of the fatal entries, dpanic doesn't panic with the production config
+const fatalLevel = zapcore.DPanicLevel
+
+//newZapLogger builds a json zap logger writing to a file rotated with the given settings and chained
+// or encrypted as configured, the closer returned closes the file
+func newZapLogger(logName string, rotation Rotation, c *config) (*zap.Logger, io.Closer, error) {
 	cfg := zap.NewProductionConfig()
 	cfg.DisableCaller = true
 	cfg.DisableStacktrace = true
 	cfg.Encoding = "json"
 	cfg.EncoderConfig.TimeKey = "timestamp"
 	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	cfg.EncoderConfig.EncodeLevel = encodeLevel
 	cfg.OutputPaths = []string{logName}
-	sw := getWriteSyncer(logName)
+	sw, closer, err := getWriteSyncer(logName, rotation, c)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	l, err := cfg.Build(SetOutput(sw, cfg))
 	if err != nil {
 		panic(err)
 	}
-	defer l.Sync()
 
-	ZapLogger = l
+	return l, closer, nil
+}
+
+//encodeLevel writes the levels in lowercase, fatal entries are written at the dpanic level so that
+// zap doesn't exit and are written as fatal
+func encodeLevel(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if level == fatalLevel {
+		enc.AppendString("fatal")
+
+		return
+	}
+	zapcore.LowercaseLevelEncoder(level, enc)
 }
 
 // SetOutput replaces existing Core with new, that writes to passed WriteSyncer.
@@ -52,9 +99,10 @@ func SetOutput(ws zapcore.WriteSyncer, conf zap.Config) zap.Option {
 	default:
 		panic("unknown encoding")
 	}
-	if runtime.GOOS == "windows" {
+	//entries of encrypted files aren't copied to stdout in plaintext
+	if runtime.GOOS == "windows" && !encryptedSyncer(ws) {
 		return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
-			syncer := zap.CombineWriteSyncers(os.Stdout, getWriteSyncer(conf.OutputPaths[0]))
+			syncer := zap.CombineWriteSyncers(os.Stdout, ws)
 			return zapcore.NewCore(enc, syncer, conf.Level)
 		})
 	} else {
@@ -64,17 +112,77 @@ func SetOutput(ws zapcore.WriteSyncer, conf zap.Config) zap.Option {
 	}
 }
 
-func getWriteSyncer(logName string) zapcore.WriteSyncer {
+//encryptedSyncer returns whether or not the syncer writes to an encrypted file
+func encryptedSyncer(ws zapcore.WriteSyncer) bool {
+	syncer, ok := ws.(WriteSyncer)
+	if !ok {
+		return false
+	}
+	writer, ok := syncer.Writer.(*fileWriter)
+
+	return ok && writer.encrypted
+}
+
+func getWriteSyncer(logName string, rotation Rotation, c *config) (zapcore.WriteSyncer, io.Closer, error) {
 	var ioWriter = &lumberjack.Logger{
 		Filename:   logName,
-		MaxSize:    10, // MB
-		MaxBackups: 10, // number of backups
-		MaxAge:     28, //days
+		MaxSize:    rotation.MaxSize,    // MB
+		MaxBackups: rotation.MaxBackups, // number of backups
+		MaxAge:     rotation.MaxAge,     //days
 		LocalTime:  true,
-		Compress:   false, // disabled by default
+		Compress:   rotation.Compress, // disabled by default, see DefaultRotation
 	}
-	var sw = WriteSyncer{
-		ioWriter,
+	var writer = &fileWriter{
+		Writer:   ioWriter,
+		closer:   ioWriter,
+		path:     logName,
+		rotation: rotation,
+	}
+	//encrypt the entries if a key is configured
+	var cipher *entryCipher
+	keys, err := encryptionKeys(c)
+	if err != nil {
+		//never fall back to plaintext if encryption was asked for
+		return nil, nil, err
+	} else if len(keys) > 0 {
+		if cipher, err = newEntryCipher(keys[0]); err != nil {
+			return nil, nil, err
+		}
 	}
-	return sw
+	//hash chain the entries if configured, the footers must be signed
+	var chain *hashChain
+	if c.logChain {
+		if c.logChainKey == "" {
+			return nil, nil, ErrChainKeyMissing
+		}
+		key, err := LoadSigningKey(c.logChainKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		chain = newHashChain(key)
+	}
+	if chain != nil || cipher != nil {
+		sink := newFileSink(ioWriter, chain, cipher, keys)
+		writer.Writer, writer.closer = sink, sink
+		writer.chained, writer.encrypted = chain != nil, cipher != nil
+	}
+	return WriteSyncer{writer}, writer, nil
+}
+
+//encryptionKeys returns the keys of the log files, the first one is used to encrypt and the others
+// can only decrypt, nil if encryption isn't configured
+func encryptionKeys(c *config) ([]EncryptionKey, error) {
+	switch {
+	case c.logEncryptionKey != "":
+		key, err := ParseEncryptionKey(c.logEncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return []EncryptionKey{key}, nil
+	case c.logEncryptionKeyFile != "":
+		return LoadEncryptionKeys(c.logEncryptionKeyFile)
+	}
+
+	return nil, nil
 }
@@ -0,0 +1,559 @@
+package router
+
+//---------------------------------------------------------------------------------------------------
+// auth.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	logger "github.com/nationaloilwellvarco/max-edge/lib-logger-go"
+)
+
+//auth constants
+const (
+	AlgRS256            string        = "RS256"
+	AlgES256            string        = "ES256"
+	AlgHS256            string        = "HS256"
+	HeaderAuthorization string        = "Authorization"
+	HeaderAuthenticate  string        = "WWW-Authenticate"
+	DefaultLeeway       time.Duration = 30 * time.Second
+	bearerPrefix        string        = "Bearer "
+	claimsContextKey    ctxKey        = "claims"
+)
+
+//AuthConfiguration provides a struct that can be used to configure an authenticator
+type AuthConfiguration struct {
+	KeyFile        string        //JWKS or PEM file holding the keys tokens are signed with, reloaded when it changes
+	Secret         []byte        //secret of HS256 tokens, can be used with or instead of the key file
+	Issuer         string        //expected iss claim, not checked if empty
+	Audience       string        //audience that the aud claim must contain, not checked if empty
+	Leeway         time.Duration //clock skew tolerated when checking exp and nbf, DefaultLeeway if 0
+	ReloadInterval time.Duration //how often the key file is checked for changes, DefaultWatchInterval if 0
+	AllowNoExpiry  bool          //whether or not tokens without exp claim are accepted, they're rejected by default
+}
+
+//Claims are the claims of a validated token
+type Claims struct {
+	Subject   string                 //sub claim
+	Issuer    string                 //iss claim
+	Audience  []string               //aud claim
+	ExpiresAt time.Time              //exp claim, zero if absent
+	NotBefore time.Time              //nbf claim, zero if absent
+	Scopes    []string               //scope claim split on spaces, or the scp claim
+	Roles     []string               //roles claim
+	Raw       map[string]interface{} //every claim of the token
+}
+
+//HasScope returns whether or not the claims have the scope
+func (c *Claims) HasScope(scope string) bool {
+	return contains(c.Scopes, scope)
+}
+
+//HasRole returns whether or not the claims have the role
+func (c *Claims) HasRole(role string) bool {
+	return contains(c.Roles, role)
+}
+
+//verificationKey is a key tokens can be verified with
+type verificationKey struct {
+	id  string      //kid of the key, empty if unknown
+	alg string      //algorithm the key is used with
+	key interface{} //*rsa.PublicKey, *ecdsa.PublicKey or []byte
+}
+
+//jwk is a key of a JWKS
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+//tokenHeader is the header of a token
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+//Authenticator validates bearer tokens, its keys are reloaded when the key file changes
+type Authenticator struct {
+	config  AuthConfiguration //configuration of the authenticator
+	keys    atomic.Value      //holds the []verificationKey tokens are verified with
+	watcher *watcher          //watcher of the key file, nil without key file
+	log     *log.Logger       //logger to print to
+}
+
+//NewAuthenticator creates an authenticator and loads its keys, the key file is watched for changes
+// until the authenticator is closed
+func NewAuthenticator(log *log.Logger, config AuthConfiguration) (a *Authenticator, err error) {
+	if config.Leeway <= 0 {
+		config.Leeway = DefaultLeeway
+	}
+	a = &Authenticator{
+		config: config,
+		log:    log,
+	}
+	if err = a.loadKeys(); err != nil {
+		a = nil
+
+		return
+	}
+	if config.KeyFile != "" {
+		a.watcher = newWatcher(config.ReloadInterval, a.reloadKeys, config.KeyFile)
+		a.watcher.LaunchWatch()
+	}
+
+	return
+}
+
+//Close stops watching the key file, it can be called more than once
+func (a *Authenticator) Close() {
+	if a.watcher != nil {
+		a.watcher.stop()
+	}
+}
+
+//loadKeys reads the keys of the key file and the secret
+func (a *Authenticator) loadKeys() (err error) {
+	var keys []verificationKey
+
+	if a.config.KeyFile != "" {
+		if keys, err = readKeyFile(a.config.KeyFile); err != nil {
+			err = ErrKeyFilef.Withf(a.config.KeyFile).Wrap(err)
+
+			return
+		}
+	}
+	if len(a.config.Secret) > 0 {
+		keys = append(keys, verificationKey{alg: AlgHS256, key: a.config.Secret})
+	}
+	if len(keys) == 0 {
+		err = ErrNoKeys
+
+		return
+	}
+	a.keys.Store(keys)
+
+	return
+}
+
+//reloadKeys reloads the keys once the key file changed, the current keys are kept if the file is
+// invalid
+func (a *Authenticator) reloadKeys() (err error) {
+	if err = a.loadKeys(); err != nil {
+		a.logln(err.Error())
+
+		return
+	}
+	a.logln("keys reloaded from " + a.config.KeyFile)
+
+	return
+}
+
+//logln can be used to write to the log provided at creation
+func (a *Authenticator) logln(info string) {
+	if a.log != nil {
+		a.log.Println(LogPrefix + info)
+	}
+}
+
+//readKeyFile reads the keys of a JWKS or of PEM public keys and certificates
+func readKeyFile(path string) (keys []verificationKey, err error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if block, _ := pem.Decode(bytes); block == nil {
+		return parseJWKS(bytes)
+	}
+	for block, rest := pem.Decode(bytes); block != nil; block, rest = pem.Decode(rest) {
+		var key interface{}
+
+		switch block.Type {
+		case "CERTIFICATE":
+			var certificate *x509.Certificate
+
+			if certificate, err = x509.ParseCertificate(block.Bytes); err != nil {
+				return
+			}
+			key = certificate.PublicKey
+		case "PUBLIC KEY":
+			if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+				return
+			}
+		case "RSA PUBLIC KEY":
+			if key, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+				return
+			}
+		default:
+			continue
+		}
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, verificationKey{alg: AlgRS256, key: key})
+		case *ecdsa.PublicKey:
+			keys = append(keys, verificationKey{alg: AlgES256, key: key})
+		}
+	}
+
+	return
+}
+
+//parseJWKS reads the RSA, P-256 and symmetric keys of a JWKS, other keys are ignored
+func parseJWKS(bytes []byte) (keys []verificationKey, err error) {
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err = json.Unmarshal(bytes, &jwks); err != nil {
+		return
+	}
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key := verificationKey{id: k.Kid}
+		switch {
+		case k.Kty == "RSA":
+			n, e := decodeBigInt(k.N), decodeBigInt(k.E)
+			if n == nil || e == nil || !e.IsInt64() {
+				err = errors.New("invalid RSA key " + k.Kid)
+
+				return
+			}
+			key.alg, key.key = AlgRS256, &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, y := decodeBigInt(k.X), decodeBigInt(k.Y)
+			if x == nil || y == nil || !elliptic.P256().IsOnCurve(x, y) {
+				err = errors.New("invalid EC key " + k.Kid)
+
+				return
+			}
+			key.alg, key.key = AlgES256, &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		case k.Kty == "oct":
+			secret, decodeErr := base64.RawURLEncoding.DecodeString(k.K)
+			if decodeErr != nil || len(secret) == 0 {
+				err = errors.New("invalid symmetric key " + k.Kid)
+
+				return
+			}
+			key.alg, key.key = AlgHS256, secret
+		default:
+			continue
+		}
+		//keys limited to another algorithm are ignored
+		if k.Alg != "" && k.Alg != key.alg {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return
+}
+
+//decodeBigInt decodes a base64url integer of a JWK, nil if invalid
+func decodeBigInt(value string) *big.Int {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(bytes) == 0 {
+		return nil
+	}
+
+	return new(big.Int).SetBytes(bytes)
+}
+
+//Validate verifies the signature of a token and checks its claims
+func (a *Authenticator) Validate(token string) (claims *Claims, err error) {
+	var header tokenHeader
+	var raw map[string]interface{}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		err = ErrInvalidTokenf.Withf("malformed token")
+
+		return
+	}
+	if err = decodeSegment(parts[0], &header); err != nil {
+		err = ErrInvalidTokenf.Withf("malformed header")
+
+		return
+	}
+	signature, decodeErr := base64.RawURLEncoding.DecodeString(parts[2])
+	if decodeErr != nil {
+		err = ErrInvalidTokenf.Withf("malformed signature")
+
+		return
+	}
+	if !a.verify(header, []byte(parts[0]+"."+parts[1]), signature) {
+		err = ErrInvalidTokenf.Withf("invalid signature")
+
+		return
+	}
+	if err = decodeSegment(parts[1], &raw); err != nil {
+		err = ErrInvalidTokenf.Withf("malformed claims")
+
+		return
+	}
+	claims = newClaims(raw)
+	err = a.checkClaims(claims)
+
+	return
+}
+
+//decodeSegment decodes a base64url json segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	bytes, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bytes, v)
+}
+
+//verify verifies the signature with the keys of the algorithm of the header, the key of the header
+// if it has a kid, the algorithm of the key must match so that a public key can't be used as a
+// secret
+func (a *Authenticator) verify(header tokenHeader, signed, signature []byte) bool {
+	keys, _ := a.keys.Load().([]verificationKey)
+	for _, key := range keys {
+		if key.alg != header.Alg || (header.Kid != "" && key.id != "" && key.id != header.Kid) {
+			continue
+		}
+		if verifySignature(key, signed, signature) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//verifySignature verifies a signature with a key
+func verifySignature(key verificationKey, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	switch key.alg {
+	case AlgRS256:
+		return rsa.VerifyPKCS1v15(key.key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case AlgES256:
+		//the signature is r followed by s
+		if len(signature) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key.key.(*ecdsa.PublicKey), digest[:], r, s)
+	case AlgHS256:
+		mac := hmac.New(sha256.New, key.key.([]byte))
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	default:
+		return false
+	}
+}
+
+//newClaims reads the registered claims, the scopes and the roles
+func newClaims(raw map[string]interface{}) (claims *Claims) {
+	claims = &Claims{Raw: raw}
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Issuer, _ = raw["iss"].(string)
+	claims.Audience = stringList(raw["aud"])
+	claims.ExpiresAt = numericDate(raw["exp"])
+	claims.NotBefore = numericDate(raw["nbf"])
+	if scope, ok := raw["scope"].(string); ok {
+		claims.Scopes = strings.Fields(scope)
+	} else {
+		claims.Scopes = stringList(raw["scp"])
+	}
+	claims.Roles = stringList(raw["roles"])
+
+	return
+}
+
+//stringList reads a claim that's either a string or an array of strings
+func stringList(claim interface{}) (list []string) {
+	switch claim := claim.(type) {
+	case string:
+		list = []string{claim}
+	case []interface{}:
+		for _, item := range claim {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+	}
+
+	return
+}
+
+//numericDate reads a claim holding seconds since the epoch, zero if absent
+func numericDate(claim interface{}) (t time.Time) {
+	if seconds, ok := claim.(float64); ok {
+		t = time.Unix(0, int64(seconds*float64(time.Second)))
+	}
+
+	return
+}
+
+//checkClaims checks the expiry, start, issuer and audience of a token, tokens must expire unless
+// allowed by the configuration
+func (a *Authenticator) checkClaims(claims *Claims) (err error) {
+	now := time.Now()
+	switch {
+	case claims.ExpiresAt.IsZero() && !a.config.AllowNoExpiry:
+		err = ErrInvalidTokenf.Withf("token without expiry")
+	case !claims.ExpiresAt.IsZero() && now.After(claims.ExpiresAt.Add(a.config.Leeway)):
+		err = ErrInvalidTokenf.Withf("token expired")
+	case !claims.NotBefore.IsZero() && now.Add(a.config.Leeway).Before(claims.NotBefore):
+		err = ErrInvalidTokenf.Withf("token not valid yet")
+	case a.config.Issuer != "" && claims.Issuer != a.config.Issuer:
+		err = ErrInvalidTokenf.Withf("unexpected issuer")
+	case a.config.Audience != "" && !contains(claims.Audience, a.config.Audience):
+		err = ErrInvalidTokenf.Withf("unexpected audience")
+	}
+
+	return
+}
+
+//contains returns whether or not a list contains a value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+//authenticate validates the bearer token of a request
+func (a *Authenticator) authenticate(request *http.Request) (claims *Claims, err error) {
+	authorization := request.Header.Get(HeaderAuthorization)
+	if len(authorization) < len(bearerPrefix) || !strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		err = ErrUnauthenticated
+
+		return
+	}
+
+	return a.Validate(strings.TrimSpace(authorization[len(bearerPrefix):]))
+}
+
+//Middleware requires a valid bearer token, the claims of the token are placed in the request context
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return a.Require(nil, nil)(next)
+}
+
+//Require returns a middleware that requires a valid bearer token with every scope and at least one of
+// the roles, a request already authenticated by another middleware isn't validated again, requests
+// without a valid token are unauthorized and requests without the scopes or roles are forbidden
+func (a *Authenticator) Require(scopes, roles []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			claims, ok := GetClaims(request.Context())
+			if !ok {
+				var err error
+
+				if claims, err = a.authenticate(request); err != nil {
+					//requests without a token aren't told about errors
+					challenge := `Bearer error="invalid_token"`
+					if errors.Is(err, ErrUnauthenticated) {
+						challenge = "Bearer"
+					}
+					writer.Header().Set(HeaderAuthenticate, challenge)
+					WriteProblem(writer, request, err)
+					return
+				}
+				ctx := context.WithValue(request.Context(), claimsContextKey, claims)
+				//the subject of the token is who makes the changes audited by the logger
+				if claims.Subject != "" {
+					ctx = logger.WithActor(ctx, claims.Subject)
+				}
+				request = request.WithContext(ctx)
+			}
+			for _, scope := range scopes {
+				if !claims.HasScope(scope) {
+					writer.Header().Set(HeaderAuthenticate, `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+					WriteProblem(writer, request, ErrMissingScopef.Withf(scope))
+					return
+				}
+			}
+			if len(roles) > 0 && !hasAnyRole(claims, roles) {
+				WriteProblem(writer, request, ErrMissingRolef.Withf(strings.Join(roles, ", ")))
+				return
+			}
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+//hasAnyRole returns whether or not the claims have at least one of the roles
+func hasAnyRole(claims *Claims, roles []string) bool {
+	for _, role := range roles {
+		if claims.HasRole(role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//GetClaims can be used to get the claims of the token that authenticated the request
+func GetClaims(ctx context.Context) (claims *Claims, ok bool) {
+	if ctx != nil {
+		claims, ok = ctx.Value(claimsContextKey).(*Claims)
+	}
+
+	return
+}
+
+//SetAuthenticator enforces the scopes and roles of the routes with the authenticator, routes without
+// scopes or roles don't require a token unless the middleware of the authenticator is used
+func (r *Router) SetAuthenticator(authenticator *Authenticator) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.started {
+		err = ErrRouterStarted
+
+		return
+	}
+	r.authenticator = authenticator
+
+	return
+}
+
+//handleFx returns the handle function of a route, wrapped so that its allowed clients are enforced
+// and its scopes and roles if the router has an authenticator
+func (r *Router) handleFx(route RouteConfiguration) http.HandlerFunc {
+	handler := http.Handler(http.HandlerFunc(route.HandleFx))
+	if r.authenticator != nil && (len(route.Scopes) > 0 || len(route.Roles) > 0) {
+		handler = r.authenticator.Require(route.Scopes, route.Roles)(handler)
+	}
+	//the client certificate is checked before the token
+	if len(route.AllowedClients) > 0 {
+		handler = AllowClients(route.AllowedClients...)(handler)
+	}
+
+	return handler.ServeHTTP
+}
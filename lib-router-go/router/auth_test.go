@@ -0,0 +1,265 @@
+package router
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logger "github.com/nationaloilwellvarco/max-edge/lib-logger-go"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+//signToken creates a token of the claims signed by the function
+func signToken(t *testing.T, alg string, claims map[string]interface{}, sign func(signed []byte) []byte) string {
+	t.Helper()
+
+	header, err := json.Marshal(tokenHeader{Alg: alg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+//hmacSigner signs tokens with a secret
+func hmacSigner(secret []byte) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+//validClaims returns claims that the authenticators of the tests accept
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   "client",
+		"iss":   "issuer",
+		"aud":   "audience",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "read write",
+		"roles": []string{"operator"},
+	}
+}
+
+//newTestAuthenticator returns an authenticator of HS256 tokens checking the issuer and audience
+func newTestAuthenticator(t *testing.T, config AuthConfiguration) *Authenticator {
+	t.Helper()
+
+	config.Secret, config.Issuer, config.Audience = testSecret, "issuer", "audience"
+	a, err := NewAuthenticator(nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(a.Close)
+
+	return a
+}
+
+func TestValidateToken(t *testing.T) {
+	a := newTestAuthenticator(t, AuthConfiguration{})
+
+	claims, err := a.Validate(signToken(t, AlgHS256, validClaims(), hmacSigner(testSecret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "client" || !claims.HasScope("write") || !claims.HasRole("operator") {
+		t.Errorf("claims read as %+v", claims)
+	}
+}
+
+func TestValidateRejectsSignature(t *testing.T) {
+	a := newTestAuthenticator(t, AuthConfiguration{})
+	token := signToken(t, AlgHS256, validClaims(), hmacSigner(testSecret))
+
+	for name, token := range map[string]string{
+		"other secret":   signToken(t, AlgHS256, validClaims(), hmacSigner([]byte("other"))),
+		"unsigned":       signToken(t, "none", validClaims(), func([]byte) []byte { return nil }),
+		"unknown alg":    signToken(t, "HS512", validClaims(), hmacSigner(testSecret)),
+		"tampered":       token[:len(token)-2] + "AA",
+		"malformed":      "not.a-token",
+		"missing header": token[len("x"):],
+	} {
+		if _, err := a.Validate(token); !errors.Is(err, ErrInvalidTokenf) {
+			t.Errorf("%s token validated with %v", name, err)
+		}
+	}
+}
+
+func TestValidateRejectsAlgorithmConfusion(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})
+	if err = os.WriteFile(keyFile, publicPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAuthenticator(nil, AuthConfiguration{KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	rsaSigner := func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+	if _, err = a.Validate(signToken(t, AlgRS256, validClaims(), rsaSigner)); err != nil {
+		t.Fatalf("RS256 token rejected: %v", err)
+	}
+	//the public key can't be used as the secret of an HS256 token
+	if _, err = a.Validate(signToken(t, AlgHS256, validClaims(), hmacSigner(publicPEM))); !errors.Is(err, ErrInvalidTokenf) {
+		t.Errorf("HS256 token signed with the public key validated with %v", err)
+	}
+	if _, err = a.Validate(signToken(t, AlgHS256, validClaims(), hmacSigner(public))); !errors.Is(err, ErrInvalidTokenf) {
+		t.Errorf("HS256 token signed with the DER public key validated with %v", err)
+	}
+}
+
+func TestValidateRejectsClaims(t *testing.T) {
+	a := newTestAuthenticator(t, AuthConfiguration{Leeway: time.Second})
+
+	for name, change := range map[string]func(claims map[string]interface{}){
+		"expired":        func(claims map[string]interface{}) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"without expiry": func(claims map[string]interface{}) { delete(claims, "exp") },
+		"not valid yet":  func(claims map[string]interface{}) { claims["nbf"] = time.Now().Add(time.Minute).Unix() },
+		"other issuer":   func(claims map[string]interface{}) { claims["iss"] = "other" },
+		"other audience": func(claims map[string]interface{}) { claims["aud"] = []string{"other"} },
+	} {
+		claims := validClaims()
+		change(claims)
+		if _, err := a.Validate(signToken(t, AlgHS256, claims, hmacSigner(testSecret))); !errors.Is(err, ErrInvalidTokenf) {
+			t.Errorf("%s token validated with %v", name, err)
+		}
+	}
+	//tokens without expiry can be allowed explicitly
+	claims := validClaims()
+	delete(claims, "exp")
+	a = newTestAuthenticator(t, AuthConfiguration{AllowNoExpiry: true})
+	if _, err := a.Validate(signToken(t, AlgHS256, claims, hmacSigner(testSecret))); err != nil {
+		t.Errorf("token without expiry rejected when allowed: %v", err)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	a := newTestAuthenticator(t, AuthConfiguration{})
+	handler := a.Require([]string{"write"}, []string{"admin", "operator"})(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if claims, ok := GetClaims(request.Context()); !ok || claims.Subject != "client" {
+			t.Error("claims not in the request context")
+		}
+	}))
+	serve := func(token string) int {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			request.Header.Set(HeaderAuthorization, bearerPrefix+token)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		return recorder.Code
+	}
+
+	if status := serve(signToken(t, AlgHS256, validClaims(), hmacSigner(testSecret))); status != http.StatusOK {
+		t.Errorf("valid token served with %d", status)
+	}
+	if status := serve(""); status != http.StatusUnauthorized {
+		t.Errorf("request without token served with %d", status)
+	}
+	claims := validClaims()
+	claims["scope"] = "read"
+	if status := serve(signToken(t, AlgHS256, claims, hmacSigner(testSecret))); status != http.StatusForbidden {
+		t.Errorf("token without scope served with %d", status)
+	}
+	claims = validClaims()
+	claims["roles"] = []string{"viewer"}
+	if status := serve(signToken(t, AlgHS256, claims, hmacSigner(testSecret))); status != http.StatusForbidden {
+		t.Errorf("token without role served with %d", status)
+	}
+}
+
+func TestRoutesRequireAuthenticator(t *testing.T) {
+	r := NewRouter(nil)
+	route := RouteConfiguration{
+		Route:    "/secured",
+		Method:   http.MethodGet,
+		HandleFx: func(http.ResponseWriter, *http.Request) {},
+		Scopes:   []string{"write"},
+	}
+
+	r.Lock()
+	r.buildRoutes([]RouteConfiguration{route})
+	err := r.rebuild()
+	r.Unlock()
+	if !errors.Is(err, ErrNoAuthenticatorf) {
+		t.Errorf("route requiring scopes built without authenticator with %v", err)
+	}
+	if err = r.SetAuthenticator(newTestAuthenticator(t, AuthConfiguration{})); err != nil {
+		t.Fatal(err)
+	}
+	r.Lock()
+	err = r.rebuild()
+	r.Unlock()
+	if err != nil {
+		t.Errorf("route requiring scopes not built with an authenticator: %v", err)
+	}
+}
+
+func TestAuthenticatorCloseTwice(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(keyFile, []byte(`{"keys":[{"kty":"oct","k":"`+base64.RawURLEncoding.EncodeToString(testSecret)+`"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAuthenticator(nil, AuthConfiguration{KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+	a.Close()
+}
+
+func TestRequireAuditActor(t *testing.T) {
+	a := newTestAuthenticator(t, AuthConfiguration{})
+	log := logger.NewLogger()
+	log.UpdateDebugMap("service", false)
+	handler := AuditContext(a.Middleware(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		log.EnableDebugContext(request.Context(), "service")
+	})))
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(HeaderAuthorization, bearerPrefix+signToken(t, AlgHS256, validClaims(), hmacSigner(testSecret)))
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	//the subject of the token is audited rather than the client ip
+	events := log.GetAuditHistory(logger.AuditFilter{})
+	if len(events) != 1 || events[0].Actor != "client" || events[0].Source != logger.AuditSourceHTTP {
+		t.Errorf("change audited as %+v", events)
+	}
+}
@@ -0,0 +1,215 @@
+package router
+
+//---------------------------------------------------------------------------------------------------
+// certreload.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+//certificate metric constants
+const (
+	MetricCertExpiry     string = "tls_certificate_expiry_timestamp_seconds"
+	metricHelpCertExpiry string = "Time the certificate expires at in seconds since the epoch"
+	certTypeServer       string = "server"
+	certTypeClientCA     string = "client_ca"
+)
+
+//certReloader serves the certificate and client CAs of the router, they're swapped when their files
+// change so that rotated certificates are used without restarting the server
+type certReloader struct {
+	certfile    string              //path to cert file
+	keyfile     string              //path to key file
+	caFile      string              //path to the bundle of client CAs, empty without client auth
+	certificate atomic.Value        //holds the *tls.Certificate served
+	clientCAs   atomic.Value        //holds the *x509.CertPool clients are verified against
+	watcher     *watcher            //watcher of the files
+	metrics     *Metrics            //metrics the expiry of the certificates is exposed in, may be nil
+	gauges      []map[string]string //labels of the expiry gauges set, removed when the files change
+	logln       func(string)        //logger of the router
+}
+
+//newCertReloader loads the certificate and the client CAs
+func newCertReloader(certfile, keyfile, caFile string, metrics *Metrics, logln func(string)) (c *certReloader, err error) {
+	c = &certReloader{
+		certfile: certfile,
+		keyfile:  keyfile,
+		caFile:   caFile,
+		metrics:  metrics,
+		logln:    logln,
+	}
+	if err = c.load(); err != nil {
+		c = nil
+	}
+
+	return
+}
+
+//load validates the files and swaps the certificate and client CAs, nothing is swapped unless
+// every file is valid
+func (c *certReloader) load() (err error) {
+	var pool *x509.CertPool
+	var cas []*x509.Certificate
+
+	certificate, leaf, err := loadCertificate(c.certfile, c.keyfile)
+	if err != nil {
+		return
+	}
+	if c.caFile != "" {
+		if pool, cas, err = loadCertPool(c.caFile); err != nil {
+			return
+		}
+	}
+	c.certificate.Store(certificate)
+	if pool != nil {
+		c.clientCAs.Store(pool)
+	}
+	c.exposeExpiry(leaf, cas)
+
+	return
+}
+
+//loadCertificate loads a certificate and its key, the key must match the certificate and the
+// certificate must be valid now
+func loadCertificate(certfile, keyfile string) (certificate *tls.Certificate, leaf *x509.Certificate, err error) {
+	pair, err := tls.LoadX509KeyPair(certfile, keyfile)
+	if err != nil {
+		err = ErrCertificatef.Withf(certfile).Wrap(err)
+
+		return
+	}
+	if leaf, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+		err = ErrCertificatef.Withf(certfile).Wrap(err)
+
+		return
+	}
+	if now := time.Now(); now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		err = ErrCertValidityf.Withf(certfile, leaf.NotBefore.Format(time.RFC3339), leaf.NotAfter.Format(time.RFC3339))
+
+		return
+	}
+	pair.Leaf = leaf
+	certificate = &pair
+
+	return
+}
+
+//exposeExpiry sets the expiry of the certificate and the client CAs in the metrics, the expiry of
+// the certificates that were replaced is removed
+func (c *certReloader) exposeExpiry(leaf *x509.Certificate, cas []*x509.Certificate) {
+	if c.metrics == nil {
+		return
+	}
+	for _, labels := range c.gauges {
+		c.metrics.DeleteGauge(MetricCertExpiry, labels)
+	}
+	c.gauges = nil
+	c.setExpiry(certTypeServer, c.certfile, leaf)
+	for _, ca := range cas {
+		c.setExpiry(certTypeClientCA, c.caFile, ca)
+	}
+}
+
+//setExpiry sets the expiry of a certificate in the metrics
+func (c *certReloader) setExpiry(certType, file string, certificate *x509.Certificate) {
+	labels := map[string]string{
+		"type":    certType,
+		"file":    file,
+		"subject": certificate.Subject.String(),
+	}
+	c.metrics.SetGauge(MetricCertExpiry, metricHelpCertExpiry, labels, float64(certificate.NotAfter.Unix()))
+	c.gauges = append(c.gauges, labels)
+}
+
+//reload reloads the files once one of them changed, the current certificate and client CAs are kept
+// if a file is invalid, e.g. while the certificate has been written but not its key yet
+func (c *certReloader) reload() (err error) {
+	if err = c.load(); err != nil {
+		c.logln("certificate not reloaded: " + err.Error())
+
+		return
+	}
+	c.logln("certificate reloaded from " + c.certfile + ", " + certExpiry(c.certificate.Load().(*tls.Certificate).Leaf))
+
+	return
+}
+
+//watch starts watching the files
+func (c *certReloader) watch(interval time.Duration) {
+	paths := []string{c.certfile, c.keyfile}
+	if c.caFile != "" {
+		paths = append(paths, c.caFile)
+	}
+	c.watcher = newWatcher(interval, c.reload, paths...)
+	c.watcher.LaunchWatch()
+}
+
+//stop stops watching the files
+func (c *certReloader) stop() {
+	if c.watcher != nil {
+		c.watcher.stop()
+	}
+}
+
+//getCertificate returns the current certificate for a handshake
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.certificate.Load().(*tls.Certificate), nil
+}
+
+//tlsConfig returns a configuration serving the current certificate, and verifying clients against
+// the current client CAs if any, the base configuration isn't modified
+func (c *certReloader) tlsConfig(base *tls.Config) (tlsConfig *tls.Config) {
+	tlsConfig = &tls.Config{}
+	if base != nil {
+		tlsConfig = base.Clone()
+	}
+	tlsConfig.Certificates, tlsConfig.GetCertificate = nil, c.getCertificate
+	if c.caFile == "" {
+		return
+	}
+	//the configuration of every handshake has the client CAs loaded last
+	handshake := tlsConfig.Clone()
+	tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		config := handshake.Clone()
+		config.ClientCAs = c.clientCAs.Load().(*x509.CertPool)
+
+		return config, nil
+	}
+
+	return
+}
+
+//loadCAs reads the certificates of a PEM bundle
+func loadCAs(bytes []byte) (cas []*x509.Certificate) {
+	for block, rest := pem.Decode(bytes); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if ca, err := x509.ParseCertificate(block.Bytes); err == nil {
+			cas = append(cas, ca)
+		}
+	}
+
+	return
+}
+
+//certReloadInterval returns the configured interval of the certificate watcher
+func certReloadInterval() time.Duration {
+	if ConfigCertWatchInterval > 0 {
+		return ConfigCertWatchInterval
+	}
+
+	return DefaultCertWatchInterval
+}
+
+//certExpiry describes the expiry of a certificate for the log
+func certExpiry(leaf *x509.Certificate) string {
+	return "serial " + leaf.SerialNumber.String() + ", expires " + leaf.NotAfter.Format(time.RFC3339) +
+		" (" + strconv.Itoa(int(time.Until(leaf.NotAfter).Hours()/24)) + " days)"
+}
@@ -3,6 +3,8 @@ package router
 import (
 	"net/http"
 	"time"
+
+	catalog "github.com/nationaloilwellvarco/max-edge/lib-errors-go/catalog"
 )
 
 //---------------------------------------------------------------------------------------------------
@@ -14,24 +16,68 @@ const (
 	LogPrefix string = "Router: "
 )
 
-//error constants
+//environment variable names
 const (
-	ErrDuplicateRoute   string = "Duplicate routes found"
-	ErrDuplicateHandle  string = "Duplicate handles found"
-	ErrRouterStarted    string = "Router already started"
-	ErrRouterNotStarted string = "Router not started"
+	EnvNameCertWatchInterval string = "certwatchinterval"
+)
+
+//errors returned by the router, see the error catalog
+var (
+	ErrDuplicateRoute    = catalog.New("RTR-001", catalog.CategoryValidation, catalog.SeverityError, "Duplicate routes found")
+	ErrDuplicateHandle   = catalog.New("RTR-002", catalog.CategoryValidation, catalog.SeverityError, "Duplicate handles found")
+	ErrRouterStarted     = catalog.New("RTR-003", catalog.CategoryConflict, catalog.SeverityError, "Router already started")
+	ErrRouterNotStarted  = catalog.New("RTR-004", catalog.CategoryConflict, catalog.SeverityError, "Router not started")
+	ErrHandleFxNil       = catalog.New("RTR-005", catalog.CategoryValidation, catalog.SeverityError, "Handle function is nil")
+	ErrMethodEmpty       = catalog.New("RTR-006", catalog.CategoryValidation, catalog.SeverityError, "Method is empty")
+	ErrInvalidMethod     = catalog.New("RTR-007", catalog.CategoryValidation, catalog.SeverityError, "Invalid method \"%s\"")
+	ErrRouteKeyNotFoundf = catalog.New("RTR-008", catalog.CategoryNotFound, catalog.SeverityWarn, "Route key \"%s\" not found")
+	ErrRouteExistsf      = catalog.New("RTR-009", catalog.CategoryConflict, catalog.SeverityError, "Route %s \"%s\" already exists")
+	ErrRouteNotFoundf    = catalog.New("RTR-010", catalog.CategoryNotFound, catalog.SeverityError, "Route %s \"%s\" not found")
+	ErrHandleNotFoundf   = catalog.New("RTR-011", catalog.CategoryNotFound, catalog.SeverityError, "Handle \"%s\" not found")
+	ErrRouterBuildf      = catalog.New("RTR-012", catalog.CategoryValidation, catalog.SeverityError, "Unable to build router: %v")
+	ErrGroupPrefixf      = catalog.New("RTR-013", catalog.CategoryValidation, catalog.SeverityError, "Invalid group prefix \"%s\"")
+	ErrGroupExistsf      = catalog.New("RTR-014", catalog.CategoryConflict, catalog.SeverityError, "Group \"%s\" already exists")
+	ErrGroupNotFoundf    = catalog.New("RTR-015", catalog.CategoryNotFound, catalog.SeverityError, "Group \"%s\" not found")
+	ErrInternal          = catalog.New("RTR-016", catalog.CategoryInternal, catalog.SeverityError, "Internal server error")
+	ErrHandlerPanicf     = catalog.New("RTR-017", catalog.CategoryInternal, catalog.SeverityError, "Panic serving %s %s: %v\n%s")
+	ErrRequestTimeout    = catalog.New("RTR-018", catalog.CategoryTimeout, catalog.SeverityWarn, "Request timed out")
+	ErrRouterNotReady    = catalog.New("RTR-019", catalog.CategoryUnavailable, catalog.SeverityWarn, "Router not ready")
+	ErrCheckTimeout      = catalog.New("RTR-020", catalog.CategoryTimeout, catalog.SeverityError, "Check timed out")
+	ErrDiskSpacef        = catalog.New("RTR-021", catalog.CategoryUnavailable, catalog.SeverityError, "Free space of \"%s\" is %d bytes, less than %d bytes")
+	ErrContentTypef      = catalog.New("RTR-022", catalog.CategoryUnsupported, catalog.SeverityWarn, "Unsupported content type \"%s\"")
+	ErrDecodeBody        = catalog.New("RTR-023", catalog.CategoryValidation, catalog.SeverityWarn, "Unable to decode body")
+	ErrBindParameterf    = catalog.New("RTR-024", catalog.CategoryValidation, catalog.SeverityWarn, "Invalid %s parameter \"%s\"")
+	ErrInvalidRequest    = catalog.New("RTR-025", catalog.CategoryValidation, catalog.SeverityWarn, "Invalid request")
+	ErrPathNotFoundf     = catalog.New("RTR-026", catalog.CategoryNotFound, catalog.SeverityInfo, "Path \"%s\" not found")
+	ErrMethodNotAllowedf = catalog.New("RTR-027", catalog.CategoryMethod, catalog.SeverityInfo, "Method %s not allowed for \"%s\"")
+	ErrBodyTooLargef     = catalog.New("RTR-028", catalog.CategoryTooLarge, catalog.SeverityWarn, "Request body larger than %d bytes")
+	ErrUnauthenticated   = catalog.New("RTR-029", catalog.CategoryUnauthorized, catalog.SeverityInfo, "Missing bearer token")
+	ErrInvalidTokenf     = catalog.New("RTR-030", catalog.CategoryUnauthorized, catalog.SeverityWarn, "Invalid token: %s")
+	ErrMissingScopef     = catalog.New("RTR-031", catalog.CategoryForbidden, catalog.SeverityWarn, "Missing scope \"%s\"")
+	ErrMissingRolef      = catalog.New("RTR-032", catalog.CategoryForbidden, catalog.SeverityWarn, "Requires one of the roles %s")
+	ErrKeyFilef          = catalog.New("RTR-033", catalog.CategoryValidation, catalog.SeverityError, "Unable to load keys from \"%s\"")
+	ErrNoKeys            = catalog.New("RTR-034", catalog.CategoryValidation, catalog.SeverityError, "No key or secret to verify tokens with")
+	ErrClientCAf         = catalog.New("RTR-035", catalog.CategoryValidation, catalog.SeverityError, "Unable to load client CAs from \"%s\"")
+	ErrClientAuthNoTLS   = catalog.New("RTR-036", catalog.CategoryValidation, catalog.SeverityError, "Client authentication requires security")
+	ErrClientCertMissing = catalog.New("RTR-037", catalog.CategoryUnauthorized, catalog.SeverityInfo, "Verified client certificate required")
+	ErrClientNotAllowedf = catalog.New("RTR-038", catalog.CategoryForbidden, catalog.SeverityWarn, "Client \"%s\" not allowed")
+	ErrCertificatef      = catalog.New("RTR-039", catalog.CategoryValidation, catalog.SeverityError, "Unable to load certificate \"%s\"")
+	ErrCertValidityf     = catalog.New("RTR-040", catalog.CategoryValidation, catalog.SeverityError, "Certificate \"%s\" is only valid from %s to %s")
+	ErrNoAuthenticatorf  = catalog.New("RTR-041", catalog.CategoryValidation, catalog.SeverityError, "Route %s \"%s\" requires scopes or roles without an authenticator")
 )
 
 //configuration constants
 var (
 	ConfigListenAndServeWait    = DefaultListenAndServeWait    //how long to block after listen and serve
 	ConfigServerShutdownTimeout = DefaultServerShutdownTimeout //how long to wait for a shutdown to occur
+	ConfigCertWatchInterval     = DefaultCertWatchInterval     //how often the certificate files are checked for changes
 )
 
 //default configuration constants
 const (
 	DefaultListenAndServeWait    time.Duration = 1 * time.Second  //default for ConfigListenAndServeWait
 	DefaultServerShutdownTimeout time.Duration = 10 * time.Second //default for ConfigServerShutdownTimeout
+	DefaultCertWatchInterval     time.Duration = 30 * time.Second //default for ConfigCertWatchInterval
 )
 
 //RouteConfiguration provides a struct that can be used to configure a route
@@ -39,6 +85,31 @@ type RouteConfiguration struct {
 	Route    string                                   //route to listen to
 	Method   string                                   //method for the provided route
 	HandleFx func(http.ResponseWriter, *http.Request) //handle function to execute on receiving request
+
+	//optional metadata used to document the route in the OpenAPI document
+	Summary     string         //short summary of the route
+	Description string         //longer description of the route
+	Tags        []string       //tags grouping the route with others
+	Request     interface{}    //value of the type of the request body, e.g. Item{}, no body if nil
+	Response    interface{}    //value of the type of the response body, no body if nil
+	Status      int            //status of a successful response, 200 if 0
+	Parameters  []ParameterDoc //descriptions of the path and query parameters
+
+	//optional authorization enforced when the router has an authenticator
+	Scopes []string //scopes the token must have, all of them
+	Roles  []string //roles the token must have, at least one of them
+
+	//optional client certificates allowed to call the route, see ClientIdentity.Matches
+	AllowedClients []string
+}
+
+//ParameterDoc describes a path or query parameter of a route
+type ParameterDoc struct {
+	Name        string //name of the parameter, the name of the variable for a path parameter
+	In          string //ParameterInPath or ParameterInQuery
+	Description string //description of the parameter
+	Required    bool   //whether or not a query parameter is required, path parameters always are
+	Type        string //json schema type of the parameter, string if empty
 }
 
 //HandleConfiguration provides a struct that can be used to configure a handle
@@ -48,6 +119,16 @@ type HandleConfiguration struct {
 	HandleFx http.Handler //handle function
 }
 
+//RouteGroup provides a struct that can be used to configure routes and handles sharing a path prefix
+// and middlewares, the routes, handles and nested groups are relative to the prefix
+type RouteGroup struct {
+	Prefix      string                            //path prefix of the group, e.g. /api/v1
+	Middlewares []func(http.Handler) http.Handler //middlewares applied to the group only
+	Routes      []RouteConfiguration              //routes of the group
+	Handles     []HandleConfiguration             //handles of the group
+	Groups      []RouteGroup                      //nested groups
+}
+
 //validMethods returns a map of valid methods for http endpoints
 func validMethods() map[string]string {
 	return map[string]string{
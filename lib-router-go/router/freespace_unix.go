@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package router
+
+//---------------------------------------------------------------------------------------------------
+// freespace_unix.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"syscall"
+)
+
+//freeSpace returns the number of bytes available to unprivileged users on the file system holding
+// the path
+func freeSpace(path string) (free uint64, err error) {
+	var stat syscall.Statfs_t
+
+	if err = syscall.Statfs(path, &stat); err != nil {
+		return
+	}
+	free = uint64(stat.Bavail) * uint64(stat.Bsize)
+
+	return
+}
@@ -0,0 +1,35 @@
+//go:build windows
+// +build windows
+
+package router
+
+//---------------------------------------------------------------------------------------------------
+// freespace_windows.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+//freeSpace returns the number of bytes available to the calling user on the volume holding the path
+func freeSpace(path string) (free uint64, err error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return
+	}
+	var totalBytes, totalFreeBytes uint64
+	ret, _, callErr := getDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&free)),
+		uintptr(unsafe.Pointer(&totalBytes)),
+		uintptr(unsafe.Pointer(&totalFreeBytes)),
+	)
+	if ret == 0 {
+		err = callErr
+	}
+
+	return
+}
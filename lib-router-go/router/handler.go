@@ -0,0 +1,368 @@
+package router
+
+//---------------------------------------------------------------------------------------------------
+// handler.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	chi "github.com/go-chi/chi"
+	catalog "github.com/nationaloilwellvarco/max-edge/lib-errors-go/catalog"
+)
+
+//handler constants
+const (
+	TagPath  string = "path"  //tag of the fields bound to a path variable, e.g. `path:"id"`
+	TagQuery string = "query" //tag of the fields bound to a query parameter, e.g. `query:"limit"`
+)
+
+//Validator can be implemented by requests to be validated once decoded and bound, errors of the
+// catalog are written as they are, other errors are invalid requests, FieldErrors are written as the
+// errors of the problem
+type Validator interface {
+	Validate() error
+}
+
+//StatusCoder can be implemented by responses to be written with a status other than 200
+type StatusCoder interface {
+	StatusCode() int
+}
+
+//NoContent can be used as the response of a handler that doesn't respond with a body, it's written
+// as a 204
+type NoContent struct{}
+
+//JSON returns a handle function that decodes the json body of the request strictly, binds the path
+// variables and query parameters to the fields tagged with path and query, validates it and writes
+// the response of the function as json, errors are written as problems so their status is given by
+// the error catalog, fields bound to the path or query should be tagged with `json:"-"`
+func JSON[Req, Resp any](fx func(ctx context.Context, request Req) (Resp, error)) func(http.ResponseWriter, *http.Request) {
+	var zero Req
+
+	//requests of a pointer type point to a new value so that they're decoded, bound and validated
+	// like values
+	requestType := reflect.TypeOf(zero)
+	pointer := requestType != nil && requestType.Kind() == reflect.Ptr
+
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var req Req
+
+		target := interface{}(&req)
+		if pointer {
+			value := reflect.New(requestType.Elem())
+			reflect.ValueOf(&req).Elem().Set(value)
+			target = value.Interface()
+		}
+		if err := decodeRequest(request, target); err != nil {
+			WriteProblem(writer, request, err)
+			return
+		}
+		resp, err := fx(request.Context(), req)
+		if err != nil {
+			//deadlines of the request context are timeouts rather than failures
+			if _, ok := catalog.As(err); !ok && errors.Is(err, context.DeadlineExceeded) {
+				err = ErrRequestTimeout.Wrap(err)
+			}
+			WriteProblem(writer, request, err)
+			return
+		}
+		writeResponse(writer, resp)
+	}
+}
+
+//JSONRoute returns the configuration of a route served by JSON, the types of the request and the
+// response and the path and query parameters are set so that the route is documented
+func JSONRoute[Req, Resp any](method, route string, fx func(ctx context.Context, request Req) (Resp, error)) (configuration RouteConfiguration) {
+	var req Req
+	var resp Resp
+
+	configuration = RouteConfiguration{
+		Route:    route,
+		Method:   method,
+		HandleFx: JSON(fx),
+	}
+	if t := reflect.TypeOf(req); t != nil && hasBody(t) {
+		configuration.Request = req
+	}
+	if _, ok := interface{}(resp).(NoContent); ok {
+		configuration.Status = http.StatusNoContent
+	} else {
+		configuration.Response = resp
+	}
+	configuration.Parameters = parameterDocs(reflect.TypeOf(req))
+
+	return
+}
+
+//decodeRequest decodes the body of a request, binds its path variables and query parameters and
+// validates it
+func decodeRequest(request *http.Request, req interface{}) (err error) {
+	//decode the body if there is one
+	if request.Body != nil && request.Body != http.NoBody {
+		if contentType := request.Header.Get("Content-Type"); contentType != "" {
+			if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != contentTypeJSON {
+				err = ErrContentTypef.Withf(contentType)
+
+				return
+			}
+		}
+		decoder := json.NewDecoder(request.Body)
+		decoder.DisallowUnknownFields()
+		if err = decoder.Decode(req); err != nil && err != io.EOF {
+			//bodies larger than the limit of the BodyLimit middleware keep their error
+			if !errors.Is(err, ErrBodyTooLargef) {
+				err = ErrDecodeBody.Wrap(err)
+			}
+
+			return
+		}
+		//a body with trailing data isn't a single json value, even if the data isn't a value itself
+		if err == nil {
+			if trailingErr := decoder.Decode(&struct{}{}); trailingErr != io.EOF {
+				err = ErrDecodeBody.Wrap(errors.New("unexpected data after the json value"))
+				if errors.Is(trailingErr, ErrBodyTooLargef) {
+					err = trailingErr
+				}
+
+				return
+			}
+		}
+		err = nil
+	}
+	//bind the path variables and query parameters
+	if value := reflect.ValueOf(req).Elem(); value.Kind() == reflect.Struct {
+		if err = bindFields(request, value); err != nil {
+			return
+		}
+	}
+	//validate, the pointer has the methods of both the value and the pointer
+	validator, ok := req.(Validator)
+	if !ok {
+		return
+	}
+	if err = validator.Validate(); err != nil {
+		if _, ok := catalog.As(err); !ok {
+			err = ErrInvalidRequest.Wrap(err)
+		}
+	}
+
+	return
+}
+
+//bindFields sets the fields tagged with path and query, the fields of embedded structs are bound as
+// well
+func bindFields(request *http.Request, value reflect.Value) (err error) {
+	query := request.URL.Query()
+	for i := 0; i < value.NumField(); i++ {
+		field, fieldValue := value.Type().Field(i), value.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err = bindFields(request, fieldValue); err != nil {
+				return
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name := field.Tag.Get(TagPath); name != "" {
+			var variable string
+
+			if chi.RouteContext(request.Context()) == nil {
+				err = ErrInternal.Wrap(ErrRouteKeyNotFoundf.Withf(name))
+
+				return
+			}
+			//a missing variable means that the route doesn't match the request type
+			if variable, err = GetRouteVariable(request, name); err != nil {
+				err = ErrInternal.Wrap(err)
+
+				return
+			}
+			if err = setField(fieldValue, []string{variable}); err != nil {
+				err = ErrBindParameterf.Withf(TagPath, name).Wrap(err)
+
+				return
+			}
+		}
+		if name := field.Tag.Get(TagQuery); name != "" {
+			values, ok := query[name]
+			if !ok {
+				continue
+			}
+			if err = setField(fieldValue, values); err != nil {
+				err = ErrBindParameterf.Withf(TagQuery, name).Wrap(err)
+
+				return
+			}
+		}
+	}
+
+	return
+}
+
+//setField sets a field from the values of a parameter, slices receive every value and other fields
+// the first one
+func setField(field reflect.Value, values []string) (err error) {
+	if len(values) == 0 {
+		return
+	}
+	//types that parse themselves
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(values[0]))
+	}
+	switch field.Kind() {
+	case reflect.Ptr:
+		value := reflect.New(field.Type().Elem())
+		if err = setField(value.Elem(), values); err != nil {
+			return
+		}
+		field.Set(value)
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err = setField(slice.Index(i), []string{value}); err != nil {
+				return
+			}
+		}
+		field.Set(slice)
+	case reflect.String:
+		field.SetString(values[0])
+	case reflect.Bool:
+		var value bool
+
+		if value, err = strconv.ParseBool(values[0]); err != nil {
+			return
+		}
+		field.SetBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var value int64
+
+		if field.Type() == reflect.TypeOf(time.Duration(0)) {
+			var duration time.Duration
+
+			if duration, err = time.ParseDuration(values[0]); err != nil {
+				return
+			}
+			field.SetInt(int64(duration))
+
+			return
+		}
+		if value, err = strconv.ParseInt(values[0], 10, field.Type().Bits()); err != nil {
+			return
+		}
+		field.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var value uint64
+
+		if value, err = strconv.ParseUint(values[0], 10, field.Type().Bits()); err != nil {
+			return
+		}
+		field.SetUint(value)
+	case reflect.Float32, reflect.Float64:
+		var value float64
+
+		if value, err = strconv.ParseFloat(values[0], field.Type().Bits()); err != nil {
+			return
+		}
+		field.SetFloat(value)
+	default:
+		err = errors.New("unsupported type " + field.Type().String())
+	}
+
+	return
+}
+
+//writeResponse writes a response as json
+func writeResponse(writer http.ResponseWriter, resp interface{}) {
+	if _, ok := resp.(NoContent); ok {
+		writer.WriteHeader(http.StatusNoContent)
+		return
+	}
+	status := http.StatusOK
+	if statusCoder, ok := resp.(StatusCoder); ok {
+		status = statusCoder.StatusCode()
+	}
+	writer.Header().Set("Content-Type", contentTypeJSON)
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(resp)
+}
+
+//hasBody returns whether or not a request type has fields decoded from the body
+func hasBody(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		switch {
+		case field.Anonymous && field.Type.Kind() == reflect.Struct:
+			if hasBody(field.Type) {
+				return true
+			}
+		case field.PkgPath != "", field.Tag.Get("json") == "-":
+		case field.Tag.Get(TagPath) != "", field.Tag.Get(TagQuery) != "":
+		default:
+			return true
+		}
+	}
+
+	return false
+}
+
+//parameterDocs returns the parameters of the fields of a request type tagged with path and query
+func parameterDocs(t reflect.Type) (docs []ParameterDoc) {
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			docs = append(docs, parameterDocs(field.Type)...)
+			continue
+		}
+		if name := field.Tag.Get(TagPath); name != "" {
+			docs = append(docs, ParameterDoc{Name: name, In: ParameterInPath, Type: parameterType(field.Type)})
+		}
+		if name := field.Tag.Get(TagQuery); name != "" {
+			docs = append(docs, ParameterDoc{Name: name, In: ParameterInQuery, Type: parameterType(field.Type)})
+		}
+	}
+
+	return
+}
+
+//parameterType returns the json schema type of a parameter, the type of the elements for slices
+func parameterType(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		return "string"
+	case strings.HasPrefix(t.Kind().String(), "int"), strings.HasPrefix(t.Kind().String(), "uint"):
+		return "integer"
+	case strings.HasPrefix(t.Kind().String(), "float"):
+		return "number"
+	case t.Kind() == reflect.Bool:
+		return "boolean"
+	default:
+		return "string"
+	}
+}
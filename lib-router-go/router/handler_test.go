@@ -0,0 +1,89 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testRequest struct {
+	Name  string `json:"name"`
+	Limit int    `json:"-" query:"limit"`
+}
+
+func (t testRequest) Validate() error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+
+	return nil
+}
+
+type testResponse struct {
+	Name  string `json:"name"`
+	Limit int    `json:"limit"`
+}
+
+//serveJSON serves a request with a body by the handle function and returns the recorded response
+func serveJSON(handleFx func(http.ResponseWriter, *http.Request), body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/test?limit=5", strings.NewReader(body))
+	request.Header.Set("Content-Type", contentTypeJSON)
+	recorder := httptest.NewRecorder()
+	handleFx(recorder, request)
+
+	return recorder
+}
+
+func TestJSONStrictDecoding(t *testing.T) {
+	handleFx := JSON(func(ctx context.Context, request testRequest) (testResponse, error) {
+		return testResponse{Name: request.Name, Limit: request.Limit}, nil
+	})
+
+	for body, status := range map[string]int{
+		`{"name":"a"}`:             http.StatusOK,
+		`{"name":"a"}` + "\n":      http.StatusOK,
+		`{"name":"a"}}`:            http.StatusBadRequest,
+		`{"name":"a"}]`:            http.StatusBadRequest,
+		`{"name":"a"}{"name":"b"}`: http.StatusBadRequest,
+		`{"name":"a"} 1`:           http.StatusBadRequest,
+		`{"name":"a","other":1}`:   http.StatusBadRequest,
+		`{"name":`:                 http.StatusBadRequest,
+	} {
+		if recorder := serveJSON(handleFx, body); recorder.Code != status {
+			t.Errorf("body %q served with %d, expected %d", body, recorder.Code, status)
+		}
+	}
+}
+
+func TestJSONPointerRequest(t *testing.T) {
+	handleFx := JSON(func(ctx context.Context, request *testRequest) (testResponse, error) {
+		return testResponse{Name: request.Name, Limit: request.Limit}, nil
+	})
+
+	recorder := serveJSON(handleFx, `{"name":"a"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("served with %d: %s", recorder.Code, recorder.Body)
+	}
+	if body := strings.TrimSpace(recorder.Body.String()); body != `{"name":"a","limit":5}` {
+		t.Errorf("pointer request decoded and bound as %s", body)
+	}
+	//the pointer is validated like a value
+	if recorder = serveJSON(handleFx, `{}`); recorder.Code != http.StatusBadRequest {
+		t.Errorf("invalid pointer request served with %d", recorder.Code)
+	}
+	//without a body the pointer is still allocated
+	request := httptest.NewRequest(http.MethodGet, "/test?limit=5", nil)
+	recorder = httptest.NewRecorder()
+	handleFx(recorder, request)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("pointer request without body served with %d", recorder.Code)
+	}
+	if docs := JSONRoute(http.MethodPost, "/test", func(ctx context.Context, request *testRequest) (testResponse, error) {
+		return testResponse{}, nil
+	}).Parameters; len(docs) != 1 || docs[0].Name != "limit" {
+		t.Errorf("parameters of a pointer request documented as %v", docs)
+	}
+}
@@ -0,0 +1,221 @@
+package router
+
+//---------------------------------------------------------------------------------------------------
+// health.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	catalog "github.com/nationaloilwellvarco/max-edge/lib-errors-go/catalog"
+)
+
+//health constants
+const (
+	HealthLivePath      string        = "/healthz/live"
+	HealthReadyPath     string        = "/healthz/ready"
+	HealthStatusUp      string        = "up"
+	HealthStatusDown    string        = "down"
+	DefaultCheckTimeout time.Duration = 5 * time.Second
+)
+
+//HealthCheck returns an error if the component it checks isn't healthy, it must return once the
+// context is done
+type HealthCheck func(ctx context.Context) error
+
+//TopicLister lists the topics of a broker, the broker connector satisfies it
+type TopicLister interface {
+	GetTopics() ([]string, error)
+}
+
+//CheckResult is the result of a single check, only the catalog code of the error is responded since
+// the errors of the checks may leak details, e.g. addresses or paths
+type CheckResult struct {
+	Status    string  `json:"status"`
+	Code      string  `json:"code,omitempty"` //catalog code of the error, empty if it isn't part of the catalog
+	Error     string  `json:"-"`              //error of the check
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+//HealthReport is the response of the health handles
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Error  string                 `json:"error,omitempty"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+//HealthChecker is a registry of the checks that decide whether or not a service is ready
+type HealthChecker struct {
+	sync.RWMutex
+	checks  map[string]HealthCheck //checks by name
+	timeout time.Duration          //how long a check may take before it fails
+}
+
+//NewHealthChecker creates a registry of checks, checks that take longer than the timeout fail
+func NewHealthChecker(timeout time.Duration) *HealthChecker {
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+
+	return &HealthChecker{
+		checks:  make(map[string]HealthCheck),
+		timeout: timeout,
+	}
+}
+
+//Register adds a check, a check with the same name is replaced
+func (h *HealthChecker) Register(name string, check HealthCheck) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.checks[name] = check
+}
+
+//Unregister removes a check
+func (h *HealthChecker) Unregister(name string) {
+	h.Lock()
+	defer h.Unlock()
+
+	delete(h.checks, name)
+}
+
+//Check runs every check concurrently, the report is up if all of them succeed
+func (h *HealthChecker) Check(ctx context.Context) (report HealthReport) {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	h.RLock()
+	checks := make(map[string]HealthCheck, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	h.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	report = HealthReport{
+		Status: HealthStatusUp,
+		Checks: make(map[string]CheckResult),
+	}
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := runCheck(ctx, check)
+			result := CheckResult{
+				Status:    HealthStatusUp,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				result.Status, result.Code, result.Error = HealthStatusDown, catalog.CodeOf(err), err.Error()
+				report.Status = HealthStatusDown
+			}
+			report.Checks[name] = result
+		}(name, check)
+	}
+	wg.Wait()
+
+	return
+}
+
+//runCheck runs a check, failing it if it doesn't return before the context is done
+func runCheck(ctx context.Context, check HealthCheck) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- check(ctx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ErrCheckTimeout
+	}
+}
+
+//BrokerCheck returns a check that succeeds if the topics of the broker can be listed, the broker isn't
+// asked once the context is done and the check returns when the context is done even if the broker
+// doesn't answer
+func BrokerCheck(lister TopicLister) HealthCheck {
+	return func(ctx context.Context) (err error) {
+		if err = ctx.Err(); err != nil {
+			err = ErrCheckTimeout.Wrap(err)
+
+			return
+		}
+		err = runCheck(ctx, func(context.Context) (err error) {
+			_, err = lister.GetTopics()
+
+			return
+		})
+
+		return
+	}
+}
+
+//DiskSpaceCheck returns a check that succeeds if the file system holding the path, e.g. the log
+// directory, has at least the given number of bytes free
+func DiskSpaceCheck(path string, minFree uint64) HealthCheck {
+	return func(ctx context.Context) error {
+		free, err := freeSpace(path)
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return ErrDiskSpacef.Withf(path, free, minFree)
+		}
+
+		return nil
+	}
+}
+
+//SetHealthChecker adds the live and ready handles to the router, the ready handle runs the checks of
+// the health checker once the router has started
+func (r *Router) SetHealthChecker(checker *HealthChecker) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.started {
+		err = ErrRouterStarted
+
+		return
+	}
+	r.healthChecker = checker
+
+	return
+}
+
+//live responds to the liveness probe, a router able to respond is alive
+func (r *Router) live(writer http.ResponseWriter, request *http.Request) {
+	writeHealth(writer, HealthReport{Status: HealthStatusUp})
+}
+
+//ready responds to the readiness probe, the router isn't ready before it has started, while it's
+// stopping or if a check fails
+func (r *Router) ready(writer http.ResponseWriter, request *http.Request) {
+	if !r.isReady() {
+		writeHealth(writer, HealthReport{Status: HealthStatusDown, Error: ErrRouterNotReady.Error()})
+		return
+	}
+	writeHealth(writer, r.healthChecker.Check(request.Context()))
+}
+
+//writeHealth writes a report, a report that's down is a service unavailable
+func writeHealth(writer http.ResponseWriter, report HealthReport) {
+	status := http.StatusOK
+	if report.Status != HealthStatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(report)
+}
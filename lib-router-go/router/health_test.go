@@ -0,0 +1,154 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//testLister lists the topics of a broker, blocking until released if set
+type testLister struct {
+	err     error
+	release chan struct{}
+	calls   int32
+}
+
+func (l *testLister) GetTopics() ([]string, error) {
+	atomic.AddInt32(&l.calls, 1)
+	if l.release != nil {
+		<-l.release
+	}
+
+	return []string{"topic"}, l.err
+}
+
+func TestHealthChecker(t *testing.T) {
+	h := NewHealthChecker(50 * time.Millisecond)
+	h.Register("up", func(ctx context.Context) error { return nil })
+	h.Register("down", func(ctx context.Context) error { return errors.New("dial tcp 10.0.0.1:9092: refused") })
+
+	report := h.Check(context.Background())
+	if report.Status != HealthStatusDown || report.Checks["up"].Status != HealthStatusUp || report.Checks["down"].Status != HealthStatusDown {
+		t.Errorf("checks reported as %+v", report)
+	}
+	if report.Checks["down"].Error == "" || report.Checks["down"].Code != "" {
+		t.Errorf("failed check reported as %+v", report.Checks["down"])
+	}
+	//a check that ignores its context times out
+	h.Register("down", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	start := time.Now()
+	report = h.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("checks returned after %v", elapsed)
+	}
+	if result := report.Checks["down"]; result.Status != HealthStatusDown || result.Code != ErrCheckTimeout.Code {
+		t.Errorf("slow check reported as %+v", result)
+	}
+	h.Unregister("down")
+	if report = h.Check(context.Background()); report.Status != HealthStatusUp || len(report.Checks) != 1 {
+		t.Errorf("checks reported as %+v", report)
+	}
+	if h = NewHealthChecker(0); h.timeout != DefaultCheckTimeout {
+		t.Errorf("default timeout is %v", h.timeout)
+	}
+}
+
+func TestBrokerCheck(t *testing.T) {
+	cause := errors.New("broker down")
+	if err := BrokerCheck(&testLister{})(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if err := BrokerCheck(&testLister{err: cause})(context.Background()); !errors.Is(err, cause) {
+		t.Errorf("failed broker checked with %v", err)
+	}
+	//the broker isn't asked once the context is done
+	lister := &testLister{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := BrokerCheck(lister)(ctx); !errors.Is(err, ErrCheckTimeout) || atomic.LoadInt32(&lister.calls) != 0 {
+		t.Errorf("broker checked with %v after the context is done", err)
+	}
+	//the check returns when the context is done even if the broker doesn't answer
+	lister = &testLister{release: make(chan struct{})}
+	defer close(lister.release)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := BrokerCheck(lister)(ctx); !errors.Is(err, ErrCheckTimeout) {
+		t.Errorf("blocked broker checked with %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("blocked broker checked after %v", elapsed)
+	}
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := DiskSpaceCheck(dir, 1)(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if err := DiskSpaceCheck(dir, 1<<62)(context.Background()); !errors.Is(err, ErrDiskSpacef) {
+		t.Errorf("full disk checked with %v", err)
+	}
+	if err := DiskSpaceCheck(filepath.Join(dir, "missing"), 1)(context.Background()); err == nil {
+		t.Error("missing path checked")
+	}
+}
+
+func TestHealthHandles(t *testing.T) {
+	r := NewRouter(nil)
+	checker := NewHealthChecker(time.Second)
+	checker.Register("disk", DiskSpaceCheck(t.TempDir(), 1<<62))
+	if err := r.SetHealthChecker(checker); err != nil {
+		t.Fatal(err)
+	}
+	r.Lock()
+	err := r.rebuild()
+	r.started = true
+	r.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.SetHealthChecker(checker); !errors.Is(err, ErrRouterStarted) {
+		t.Errorf("health checker set with %v once started", err)
+	}
+
+	//the router isn't ready before it serves
+	if status := serveStatus(r.serveHTTP, http.MethodGet, HealthLivePath); status != http.StatusOK {
+		t.Errorf("live responded %d", status)
+	}
+	if status := serveStatus(r.serveHTTP, http.MethodGet, HealthReadyPath); status != http.StatusServiceUnavailable {
+		t.Errorf("ready responded %d before serving", status)
+	}
+	atomic.StoreInt32(&r.readyState, 1)
+	recorder := httptest.NewRecorder()
+	r.serveHTTP(recorder, httptest.NewRequest(http.MethodGet, HealthReadyPath, nil))
+	var report HealthReport
+	if err = json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("failed check responded %d %v", recorder.Code, recorder.Header())
+	}
+	//the errors of the checks are reported by their code only
+	if result := report.Checks["disk"]; result.Status != HealthStatusDown || result.Code != ErrDiskSpacef.Code || result.Error != "" {
+		t.Errorf("failed check reported as %+v", result)
+	}
+	checker.Unregister("disk")
+	recorder = httptest.NewRecorder()
+	r.serveHTTP(recorder, httptest.NewRequest(http.MethodGet, HealthReadyPath, nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"status":"up"`) {
+		t.Errorf("ready responded %d %s", recorder.Code, recorder.Body.String())
+	}
+}
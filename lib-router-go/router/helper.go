@@ -5,7 +5,6 @@ package router
 //---------------------------------------------------------------------------------------------------
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -20,6 +19,7 @@ import (
 func SetConfigDefault() {
 	ConfigListenAndServeWait = DefaultListenAndServeWait
 	ConfigServerShutdownTimeout = DefaultServerShutdownTimeout
+	ConfigCertWatchInterval = DefaultCertWatchInterval
 }
 
 //SetConfigFromEnv can be used to read a map of environmental variables, grab the keys and convert
@@ -59,6 +59,23 @@ func SetConfigFromEnv(envs map[string]string) {
 			}
 		}
 	}
+	//get the configuration option for the certificate watch interval
+	if certWatchInterval, ok := envs[EnvNameCertWatchInterval]; ok {
+		//ensure that its not empty
+		if certWatchInterval != "" {
+			//attempt to convert to an integer
+			if certWatchIntervalInt, err := strconv.Atoi(certWatchInterval); err == nil {
+				//set integer in milliseconds
+				certWatchIntervalDuration := time.Duration(certWatchIntervalInt) * time.Millisecond
+				//ensure that it's not less or equal to 0
+				if certWatchIntervalDuration <= 0 {
+					certWatchIntervalDuration = DefaultCertWatchInterval
+				}
+				//set configuration
+				ConfigCertWatchInterval = certWatchIntervalDuration
+			}
+		}
+	}
 
 }
 
@@ -71,19 +88,19 @@ func ValidateRoutes(routes []RouteConfiguration) (err error) {
 	for _, route := range routes {
 		//check if handle function is nil
 		if route.HandleFx == nil {
-			err = errors.New(ErrHandleFxNil)
+			err = ErrHandleFxNil
 
 			return
 		}
 		//check if method is empty
 		if route.Method == "" {
-			err = errors.New(ErrMethodEmpty)
+			err = ErrMethodEmpty
 
 			return
 		}
 		//check if method is valid
 		if _, ok := validMethods()[route.Method]; !ok {
-			err = fmt.Errorf(ErrInvalidMethod, route.Method)
+			err = ErrInvalidMethod.Withf(route.Method)
 
 			return
 		}
@@ -93,7 +110,7 @@ func ValidateRoutes(routes []RouteConfiguration) (err error) {
 	}
 	//validate routes (check to see if there are any duplicate routes)
 	//if len(routeMap) != len(routes) {
-	//	err = errors.New(ErrDuplicateRoute)
+	//	err = ErrDuplicateRoute
 
 	//	return
 	//}
@@ -110,7 +127,7 @@ func ValidateHandles(handles []HandleConfiguration) (err error) {
 	for _, handle := range handles {
 		//check if handle function is nil
 		if handle.HandleFx == nil {
-			err = errors.New(ErrHandleFxNil)
+			err = ErrHandleFxNil
 
 			return
 		}
@@ -119,7 +136,7 @@ func ValidateHandles(handles []HandleConfiguration) (err error) {
 	}
 	//validate routes (check to see if there are any duplicate routes)
 	//if len(handleMap) != len(handles) {
-	//	err = errors.New(ErrDuplicateHandle)
+	//	err = ErrDuplicateHandle
 
 	//	return
 	//}
@@ -127,6 +144,72 @@ func ValidateHandles(handles []HandleConfiguration) (err error) {
 	return
 }
 
+//ValidateGroups can be used to confirm that a slice of provided groups, their routes, handles and
+// nested groups have no common errors
+func ValidateGroups(groups []RouteGroup) (err error) {
+	var prefixMap = make(map[string]struct{})
+	var empty struct{}
+
+	for _, group := range groups {
+		//check that the prefix is a path below the root without a trailing slash
+		if len(group.Prefix) < 2 || !strings.HasPrefix(group.Prefix, "/") || strings.HasSuffix(group.Prefix, "/") {
+			err = ErrGroupPrefixf.Withf(group.Prefix)
+
+			return
+		}
+		//check for groups with the same prefix at the same level
+		if _, ok := prefixMap[group.Prefix]; ok {
+			err = ErrGroupExistsf.Withf(group.Prefix)
+
+			return
+		}
+		prefixMap[group.Prefix] = empty
+		//validate the content of the group
+		if err = ValidateRoutes(group.Routes); err != nil {
+			return
+		}
+		if err = ValidateHandles(group.Handles); err != nil {
+			return
+		}
+		if err = ValidateGroups(group.Groups); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+//FlattenGroups can be used to list the routes and handles of groups with their full path
+func FlattenGroups(groups []RouteGroup) (routes []RouteConfiguration, handles []HandleConfiguration) {
+	for _, group := range groups {
+		for _, route := range group.Routes {
+			route.Route = joinPath(group.Prefix, route.Route)
+			routes = append(routes, route)
+		}
+		for _, handle := range group.Handles {
+			handle.Route = joinPath(group.Prefix, handle.Route)
+			handles = append(handles, handle)
+		}
+		groupRoutes, groupHandles := FlattenGroups(group.Groups)
+		for _, route := range groupRoutes {
+			route.Route = joinPath(group.Prefix, route.Route)
+			routes = append(routes, route)
+		}
+		for _, handle := range groupHandles {
+			handle.Route = joinPath(group.Prefix, handle.Route)
+			handles = append(handles, handle)
+		}
+	}
+
+	return
+}
+
+//joinPath joins a group prefix and a path relative to it the way chi does, the root of a group keeps
+// its trailing slash, e.g. / in the /api group is /api/
+func joinPath(prefix, path string) string {
+	return prefix + "/" + strings.TrimPrefix(path, "/")
+}
+
 //GetRouteVariable can be used to pull variables out of the given request's route
 func GetRouteVariable(request *http.Request, key string) (value string, err error) {
 	//get the route context
@@ -142,7 +225,24 @@ func GetRouteVariable(request *http.Request, key string) (value string, err erro
 		}
 	}
 	//output error since key not found
-	err = fmt.Errorf(ErrRouteKeyNotFoundf, key)
+	err = ErrRouteKeyNotFoundf.Withf(key)
+
+	return
+}
+
+//RouteVariables returns the names of the variables of a route, e.g. id for /items/{id}, the regular
+// expression of a variable isn't part of its name
+func RouteVariables(route string) (names []string) {
+	for _, segment := range strings.Split(route, "/") {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}")
+		if i := strings.Index(name, ":"); i >= 0 {
+			name = name[:i]
+		}
+		names = append(names, name)
+	}
 
 	return
 }
@@ -171,6 +271,33 @@ func SortRoutes(routes []RouteConfiguration) (sortedRoutes []RouteConfiguration)
 	return
 }
 
+// SortGroups sorts groups the same way as routes, putting the groups with no variables in their prefix
+// in front, the routes, handles and nested groups of every group are sorted as well
+func SortGroups(groups []RouteGroup) (sortedGroups []RouteGroup) {
+	var groupsWithVariables []RouteGroup
+	var groupsWithNoVariables []RouteGroup
+
+	//range over the groups
+	for _, group := range groups {
+		//sort the content of the group
+		group.Routes = SortRoutes(group.Routes)
+		group.Handles = SortHandles(group.Handles)
+		group.Groups = SortGroups(group.Groups)
+		//switch on conditions to determine how to sort
+		switch {
+		case strings.Contains(group.Prefix, "{"), strings.Contains(group.Prefix, "}"):
+			groupsWithVariables = append(groupsWithVariables, group)
+		default:
+			groupsWithNoVariables = append(groupsWithNoVariables, group)
+		}
+	}
+	//sort the groups, putting the groups with no variables in front
+	sortedGroups = append(sortedGroups, groupsWithNoVariables...)
+	sortedGroups = append(sortedGroups, groupsWithVariables...)
+
+	return
+}
+
 // SortHandles sorts handles
 func SortHandles(handles []HandleConfiguration) (sortedHandles []HandleConfiguration) {
 	var handlesWithVariables []HandleConfiguration
@@ -200,3 +327,9 @@ func SortHandles(handles []HandleConfiguration) (sortedHandles []HandleConfigura
 
 	return
 }
+
+//WriteError can be used by handle functions to respond with the problem of an error, WriteProblem
+// should be preferred since the problem then includes the path and id of the request
+func WriteError(writer http.ResponseWriter, err error) {
+	WriteProblem(writer, nil, err)
+}
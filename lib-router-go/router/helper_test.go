@@ -0,0 +1,116 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	chi "github.com/go-chi/chi"
+)
+
+//noContent is a handle function writing no content
+func noContent(writer http.ResponseWriter, request *http.Request) {
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+//testGroups returns nested groups with a route at the root of each group
+func testGroups() []RouteGroup {
+	return []RouteGroup{{
+		Prefix: "/api",
+		Routes: []RouteConfiguration{
+			{Route: "/", Method: http.MethodGet, HandleFx: noContent},
+			{Route: "/items/{id}", Method: http.MethodGet, HandleFx: noContent},
+		},
+		Handles: []HandleConfiguration{{Route: "/files", HandleFx: http.HandlerFunc(noContent)}},
+		Groups: []RouteGroup{{
+			Prefix: "/v1",
+			Routes: []RouteConfiguration{
+				{Route: "/", Method: http.MethodGet, HandleFx: noContent},
+				{Route: "/status", Method: http.MethodGet, HandleFx: noContent},
+			},
+		}},
+	}}
+}
+
+func TestFlattenGroups(t *testing.T) {
+	routes, handles := FlattenGroups(testGroups())
+
+	var paths []string
+	for _, route := range routes {
+		paths = append(paths, route.Route)
+	}
+	sort.Strings(paths)
+	want := []string{"/api/", "/api/items/{id}", "/api/v1/", "/api/v1/status"}
+	if len(paths) != len(want) {
+		t.Fatalf("flattened routes are %v", paths)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("flattened routes are %v", paths)
+			break
+		}
+	}
+	if len(handles) != 1 || handles[0].Route != "/api/files" {
+		t.Errorf("flattened handles are %v", handles)
+	}
+}
+
+func TestFlattenGroupsMatchesChi(t *testing.T) {
+	var patterns []string
+	groups := testGroups()
+	record := func(writer http.ResponseWriter, request *http.Request) {
+		patterns = append(patterns, chi.RouteContext(request.Context()).RoutePattern())
+	}
+	groups[0].Routes[0].HandleFx, groups[0].Groups[0].Routes[0].HandleFx = record, record
+
+	r := NewRouter(nil)
+	r.Lock()
+	r.groups["/api"] = groups[0]
+	err := r.rebuild()
+	r.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the flattened routes are the patterns chi reports for the roots of the groups
+	for _, path := range []string{"/api", "/api/v1"} {
+		r.serveHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	routes, _ := FlattenGroups(groups)
+	flattened := make(map[string]bool)
+	for _, route := range routes {
+		flattened[route.Route] = true
+	}
+	if len(patterns) != 2 {
+		t.Fatalf("roots of the groups served as %v", patterns)
+	}
+	for _, pattern := range patterns {
+		if !flattened[pattern] {
+			t.Errorf("chi pattern %s isn't a flattened route", pattern)
+		}
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	route := RouteConfiguration{Route: "/", Method: http.MethodGet, HandleFx: noContent}
+
+	for name, test := range map[string]struct {
+		groups []RouteGroup
+		err    error
+	}{
+		"valid":            {testGroups(), nil},
+		"root prefix":      {[]RouteGroup{{Prefix: "/"}}, ErrGroupPrefixf},
+		"relative prefix":  {[]RouteGroup{{Prefix: "api"}}, ErrGroupPrefixf},
+		"trailing slash":   {[]RouteGroup{{Prefix: "/api/"}}, ErrGroupPrefixf},
+		"prefix twice":     {[]RouteGroup{{Prefix: "/api"}, {Prefix: "/api"}}, ErrGroupExistsf},
+		"invalid route":    {[]RouteGroup{{Prefix: "/api", Routes: []RouteConfiguration{{Route: "/", Method: "FETCH", HandleFx: noContent}}}}, ErrInvalidMethod},
+		"invalid handle":   {[]RouteGroup{{Prefix: "/api", Handles: []HandleConfiguration{{Route: "/files"}}}}, ErrHandleFxNil},
+		"invalid nested":   {[]RouteGroup{{Prefix: "/api", Groups: []RouteGroup{{Prefix: "v1"}}}}, ErrGroupPrefixf},
+		"nested same name": {[]RouteGroup{{Prefix: "/api", Routes: []RouteConfiguration{route}, Groups: []RouteGroup{{Prefix: "/api"}}}}, nil},
+	} {
+		if err := ValidateGroups(test.groups); !errors.Is(err, test.err) || (test.err == nil) != (err == nil) {
+			t.Errorf("%s groups validated with %v", name, err)
+		}
+	}
+}
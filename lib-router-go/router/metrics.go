@@ -0,0 +1,403 @@
+package router
+
+//---------------------------------------------------------------------------------------------------
+// metrics.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	chi "github.com/go-chi/chi"
+	middleware "github.com/go-chi/chi/middleware"
+)
+
+//metrics constants
+const (
+	DefaultMetricsRoute  string = "/metrics"
+	MetricsContentType   string = "text/plain; version=0.0.4; charset=utf-8"
+	MetricRequests       string = "http_requests_total"
+	MetricDuration       string = "http_request_duration_seconds"
+	MetricResponseSize   string = "http_response_size_bytes"
+	MetricInFlight       string = "http_requests_in_flight"
+	unmatchedRoute       string = "unmatched" //route label of requests that didn't match a route
+	otherMethod          string = "other"     //method label of requests with a non standard method
+	seededStatusClass    string = "2xx"       //status label of the series of routes without traffic
+	metricTypeCounter    string = "counter"
+	metricTypeGauge      string = "gauge"
+	metricTypeHistogram  string = "histogram"
+	metricLabelMethod    string = "method"
+	metricLabelRoute     string = "route"
+	metricLabelStatus    string = "status"
+	metricHelpRequests   string = "Number of requests served"
+	metricHelpDuration   string = "Time taken to serve requests in seconds"
+	metricHelpSize       string = "Size of the responses in bytes"
+	metricHelpInFlight   string = "Number of requests being served"
+	metricBucketInfinity string = "+Inf"
+)
+
+//DefaultDurationBuckets are the upper bounds of the latency histogram in seconds
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+//DefaultSizeBuckets are the upper bounds of the response size histogram in bytes
+var DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+//seriesKey identifies the series of a route
+type seriesKey struct {
+	method string
+	route  string //route pattern, never the raw path
+	status string //status class, e.g. 2xx
+}
+
+//histogram counts observations in buckets, counts are per bucket and made cumulative when written
+type histogram struct {
+	counts []uint64 //one count per bucket, the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+//series holds the metrics of a route
+type series struct {
+	requests uint64
+	duration histogram
+	size     histogram
+}
+
+//gauge is a value set by the owner of the metrics, e.g. the expiry of a certificate
+type gauge struct {
+	help   string
+	values map[string]float64 //values by formatted labels
+}
+
+//Metrics records the requests served by a router and writes them in the Prometheus text format
+type Metrics struct {
+	sync.Mutex
+	durationBuckets []float64             //upper bounds of the latency histogram
+	sizeBuckets     []float64             //upper bounds of the response size histogram
+	series          map[seriesKey]*series //series by method, route and status class
+	gauges          map[string]*gauge     //gauges by name
+	inFlight        int64                 //number of requests being served, accessed atomically
+}
+
+//NewMetrics creates metrics with the given histogram buckets, the defaults are used if nil
+func NewMetrics(durationBuckets, sizeBuckets []float64) *Metrics {
+	if len(durationBuckets) == 0 {
+		durationBuckets = DefaultDurationBuckets
+	}
+	if len(sizeBuckets) == 0 {
+		sizeBuckets = DefaultSizeBuckets
+	}
+	durationBuckets = append([]float64(nil), durationBuckets...)
+	sizeBuckets = append([]float64(nil), sizeBuckets...)
+	sort.Float64s(durationBuckets)
+	sort.Float64s(sizeBuckets)
+
+	return &Metrics{
+		durationBuckets: durationBuckets,
+		sizeBuckets:     sizeBuckets,
+		series:          make(map[seriesKey]*series),
+		gauges:          make(map[string]*gauge),
+	}
+}
+
+//Seed adds a series for each route so that routes without traffic are reported with zero counts
+func (m *Metrics) Seed(routes []RouteConfiguration) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, route := range routes {
+		m.getSeries(seriesKey{method: route.Method, route: route.Route, status: seededStatusClass})
+	}
+}
+
+//Unseed removes the series added by Seed for each route, series of routes that served requests are
+// kept so that their counts aren't lost
+func (m *Metrics) Unseed(routes []RouteConfiguration) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, route := range routes {
+		key := seriesKey{method: route.Method, route: route.Route, status: seededStatusClass}
+		if s, ok := m.series[key]; ok && s.requests == 0 {
+			delete(m.series, key)
+		}
+	}
+}
+
+//getSeries returns the series of the key, creating it if needed, must be called with the lock held
+func (m *Metrics) getSeries(key seriesKey) *series {
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{
+			duration: histogram{counts: make([]uint64, len(m.durationBuckets)+1)},
+			size:     histogram{counts: make([]uint64, len(m.sizeBuckets)+1)},
+		}
+		m.series[key] = s
+	}
+
+	return s
+}
+
+//observe adds a value to the histogram
+func (h *histogram) observe(buckets []float64, value float64) {
+	i := sort.SearchFloat64s(buckets, value)
+	h.counts[i]++
+	h.sum += value
+	h.count++
+}
+
+//record adds a served request to the metrics
+func (m *Metrics) record(method, route string, status, size int, duration time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	s := m.getSeries(seriesKey{method: method, route: route, status: statusClass(status)})
+	s.requests++
+	s.duration.observe(m.durationBuckets, duration.Seconds())
+	s.size.observe(m.sizeBuckets, float64(size))
+}
+
+//standardMethods are the methods reported by their name, other methods are reported as one so that
+// clients can't create series
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+//methodLabel returns the method label of a request method
+func methodLabel(method string) string {
+	if standardMethods[method] {
+		return method
+	}
+
+	return otherMethod
+}
+
+//statusClass returns the class of a status code, e.g. 4xx for 404
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+//Middleware records the requests it serves, the route label is the route pattern that matched the
+// request so that paths with variables don't create a series each
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		atomic.AddInt64(&m.inFlight, 1)
+		defer atomic.AddInt64(&m.inFlight, -1)
+
+		wrapped := middleware.NewWrapResponseWriter(writer, request.ProtoMajor)
+		defer func() {
+			status := wrapped.Status()
+			//nothing written means the handler returned without writing a status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			//the pattern is known once the request has been routed
+			route := unmatchedRoute
+			if ctx := chi.RouteContext(request.Context()); ctx != nil && ctx.RoutePattern() != "" {
+				route = ctx.RoutePattern()
+			}
+			m.record(methodLabel(request.Method), route, status, wrapped.BytesWritten(), time.Since(start))
+		}()
+		next.ServeHTTP(wrapped, request)
+	})
+}
+
+//SetGauge sets the value of a gauge with the given labels, the gauge is created if needed
+func (m *Metrics) SetGauge(name, help string, labels map[string]string, value float64) {
+	m.Lock()
+	defer m.Unlock()
+
+	g, ok := m.gauges[name]
+	if !ok {
+		g = &gauge{help: help, values: make(map[string]float64)}
+		m.gauges[name] = g
+	}
+	g.values[formatLabels(labels)] = value
+}
+
+//DeleteGauge removes the value of a gauge with the given labels
+func (m *Metrics) DeleteGauge(name string, labels map[string]string) {
+	m.Lock()
+	defer m.Unlock()
+
+	if g, ok := m.gauges[name]; ok {
+		delete(g.values, formatLabels(labels))
+	}
+}
+
+//formatLabels formats labels sorted by name, e.g. {method="GET",route="/"}
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"=\""+escapeLabel(labels[name])+"\"")
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+//escapeLabel escapes a label value as required by the text format
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+//formatFloat formats a value as required by the text format
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+//WriteTo writes the metrics in the Prometheus text format
+func (m *Metrics) WriteTo(writer io.Writer) (n int64, err error) {
+	var builder strings.Builder
+
+	m.Lock()
+	keys := make([]seriesKey, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+	labels := make([]map[string]string, len(keys))
+	for i, key := range keys {
+		labels[i] = map[string]string{
+			metricLabelMethod: key.method,
+			metricLabelRoute:  key.route,
+			metricLabelStatus: key.status,
+		}
+	}
+	writeHeader(&builder, MetricRequests, metricHelpRequests, metricTypeCounter)
+	for i, key := range keys {
+		fmt.Fprintf(&builder, "%s%s %d\n", MetricRequests, formatLabels(labels[i]), m.series[key].requests)
+	}
+	writeHeader(&builder, MetricDuration, metricHelpDuration, metricTypeHistogram)
+	for i, key := range keys {
+		writeHistogram(&builder, MetricDuration, labels[i], m.durationBuckets, m.series[key].duration)
+	}
+	writeHeader(&builder, MetricResponseSize, metricHelpSize, metricTypeHistogram)
+	for i, key := range keys {
+		writeHistogram(&builder, MetricResponseSize, labels[i], m.sizeBuckets, m.series[key].size)
+	}
+	writeHeader(&builder, MetricInFlight, metricHelpInFlight, metricTypeGauge)
+	fmt.Fprintf(&builder, "%s %d\n", MetricInFlight, atomic.LoadInt64(&m.inFlight))
+	names := make([]string, 0, len(m.gauges))
+	for name := range m.gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g := m.gauges[name]
+		writeHeader(&builder, name, g.help, metricTypeGauge)
+		values := make([]string, 0, len(g.values))
+		for labels := range g.values {
+			values = append(values, labels)
+		}
+		sort.Strings(values)
+		for _, labels := range values {
+			fmt.Fprintf(&builder, "%s%s %s\n", name, labels, formatFloat(g.values[labels]))
+		}
+	}
+	m.Unlock()
+	written, err := io.WriteString(writer, builder.String())
+	n = int64(written)
+
+	return
+}
+
+//writeHeader writes the help and type lines of a metric
+func writeHeader(builder *strings.Builder, name, help, metricType string) {
+	fmt.Fprintf(builder, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+//writeHistogram writes the cumulative buckets, sum and count of a histogram
+func writeHistogram(builder *strings.Builder, name string, labels map[string]string, buckets []float64, h histogram) {
+	var cumulative uint64
+
+	bucketLabels := make(map[string]string, len(labels)+1)
+	for label, value := range labels {
+		bucketLabels[label] = value
+	}
+	for i, count := range h.counts {
+		cumulative += count
+		bucketLabels["le"] = metricBucketInfinity
+		if i < len(buckets) {
+			bucketLabels["le"] = formatFloat(buckets[i])
+		}
+		fmt.Fprintf(builder, "%s_bucket%s %d\n", name, formatLabels(bucketLabels), cumulative)
+	}
+	fmt.Fprintf(builder, "%s_sum%s %s\n", name, formatLabels(labels), formatFloat(h.sum))
+	fmt.Fprintf(builder, "%s_count%s %d\n", name, formatLabels(labels), h.count)
+}
+
+//ServeHTTP responds with the metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", MetricsContentType)
+	m.WriteTo(writer)
+}
+
+//SetMetrics records the requests served by the router and adds a route responding with the metrics,
+// the default route is used if empty, the configured routes are reported even without traffic
+func (r *Router) SetMetrics(metrics *Metrics, route string) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.started {
+		err = ErrRouterStarted
+
+		return
+	}
+	if route == "" {
+		route = DefaultMetricsRoute
+	}
+	r.metrics, r.metricsRoute = metrics, route
+
+	return
+}
+
+//seedMetrics adds the series of the configured routes, must be called with the lock held
+func (r *Router) seedMetrics(routes []RouteConfiguration, groups []RouteGroup) {
+	if r.metrics == nil {
+		return
+	}
+	groupRoutes, _ := FlattenGroups(groups)
+	r.metrics.Seed(append(append([]RouteConfiguration(nil), routes...), groupRoutes...))
+}
+
+//unseedMetrics removes the series of removed routes that never served requests, must be called with
+// the lock held
+func (r *Router) unseedMetrics(routes []RouteConfiguration, groups []RouteGroup) {
+	if r.metrics == nil {
+		return
+	}
+	groupRoutes, _ := FlattenGroups(groups)
+	r.metrics.Unseed(append(append([]RouteConfiguration(nil), routes...), groupRoutes...))
+}
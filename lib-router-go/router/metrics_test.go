@@ -0,0 +1,51 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnseedKeepsServedRoutes(t *testing.T) {
+	m := NewMetrics(nil, nil)
+	idle := RouteConfiguration{Method: http.MethodGet, Route: "/idle"}
+	served := RouteConfiguration{Method: http.MethodGet, Route: "/served"}
+	m.Seed([]RouteConfiguration{idle, served})
+	m.record(http.MethodGet, "/served", http.StatusOK, 10, time.Millisecond)
+
+	m.Unseed([]RouteConfiguration{idle, served})
+	var builder strings.Builder
+	if _, err := m.WriteTo(&builder); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(builder.String(), `route="/idle"`) {
+		t.Error("removed route without traffic still reported")
+	}
+	if !strings.Contains(builder.String(), MetricRequests+`{method="GET",route="/served",status="2xx"} 1`) {
+		t.Error("removed route lost the requests it served")
+	}
+}
+
+func TestMiddlewareMethodLabel(t *testing.T) {
+	m := NewMetrics(nil, nil)
+	handler := m.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for _, method := range []string{http.MethodGet, "BOGUS", "MADEUP1", "MADEUP2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+	}
+
+	var builder strings.Builder
+	if _, err := m.WriteTo(&builder); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(builder.String(), "BOGUS") || strings.Contains(builder.String(), "MADEUP") {
+		t.Error("non standard methods reported by their name")
+	}
+	if !strings.Contains(builder.String(), MetricRequests+`{method="other",route="unmatched",status="2xx"} 3`) {
+		t.Error("non standard methods not reported as other")
+	}
+	if !strings.Contains(builder.String(), MetricRequests+`{method="GET",route="unmatched",status="2xx"} 1`) {
+		t.Error("standard method not reported by its name")
+	}
+}
@@ -0,0 +1,308 @@
+package router
+
+//---------------------------------------------------------------------------------------------------
+// middleware.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	chi "github.com/go-chi/chi"
+	middleware "github.com/go-chi/chi/middleware"
+	logger "github.com/nationaloilwellvarco/max-edge/lib-logger-go"
+)
+
+//middleware constants
+const (
+	HeaderRequestID     string = "X-Request-Id"
+	HeaderRealIP        string = "X-Real-Ip"
+	HeaderForwardedFor  string = "X-Forwarded-For"
+	maxRequestIDLength  int    = 128
+	requestIDContextKey ctxKey = "requestID"
+)
+
+//ctxKey is the type of the keys of the values the middlewares store in the request context
+type ctxKey string
+
+//AccessEntry is the content of an entry written by AccessLog
+type AccessEntry struct {
+	RequestID string  `json:"requestId,omitempty"`
+	Method    string  `json:"method"`
+	Route     string  `json:"route"` //route pattern that matched the request
+	Path      string  `json:"path"`
+	Status    int     `json:"status"`
+	Bytes     int     `json:"bytes"`
+	LatencyMs float64 `json:"latencyMs"`
+	ClientIP  string  `json:"clientIp"`
+}
+
+//RequestID is a middleware that gives every request an id, the id of the X-Request-Id header is
+// kept if valid so that it's propagated across services, the id is written in the response header
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requestID := request.Header.Get(HeaderRequestID)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+			request.Header.Set(HeaderRequestID, requestID)
+		}
+		writer.Header().Set(HeaderRequestID, requestID)
+		ctx := context.WithValue(request.Context(), requestIDContextKey, requestID)
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+//GetRequestID can be used to get the id given to the request by the RequestID middleware
+func GetRequestID(ctx context.Context) (requestID string) {
+	if ctx != nil {
+		requestID, _ = ctx.Value(requestIDContextKey).(string)
+	}
+
+	return
+}
+
+//validRequestID returns whether or not a request id received from a client can be used
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+//newRequestID generates a random request id
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+//RealIP is a middleware that sets the remote address of the request to the client ip given by the
+// X-Real-Ip or X-Forwarded-For headers, it must only be used behind a proxy that sets these headers
+// since clients can set them as well
+func RealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if realIP := realIP(request); realIP != "" {
+			request.RemoteAddr = realIP
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+//realIP returns the client ip given by the proxy headers, empty if there is none
+func realIP(request *http.Request) (ip string) {
+	if realIP := strings.TrimSpace(request.Header.Get(HeaderRealIP)); realIP != "" {
+		ip = realIP
+	} else if forwardedFor := request.Header.Get(HeaderForwardedFor); forwardedFor != "" {
+		//the first address is the client, the following ones are the proxies
+		ip = strings.TrimSpace(strings.Split(forwardedFor, ",")[0])
+	}
+	if net.ParseIP(ip) == nil {
+		ip = ""
+	}
+
+	return
+}
+
+//ClientIP can be used to get the ip of the client of a request
+func ClientIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+
+	return host
+}
+
+//AuditContext is a middleware that tells the logger that the changes made while serving a request,
+// e.g. of the debug mode, come from http, the actor is the verified client certificate or the client
+// ip until the request is authenticated by a token
+func AuditContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		actor := ClientIP(request)
+		if identity, ok := clientIdentity(request); ok {
+			actor = identity.Subject
+		}
+		ctx := logger.WithActor(logger.WithSource(request.Context(), logger.AuditSourceHTTP), actor)
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+//AccessLog returns a middleware that writes an entry for every request to the logger under the
+// service name, server errors are written as errors
+func AccessLog(log logger.Logger, serviceName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			start := time.Now()
+			wrapped := middleware.NewWrapResponseWriter(writer, request.ProtoMajor)
+			defer func() {
+				entry := AccessEntry{
+					RequestID: GetRequestID(request.Context()),
+					Method:    request.Method,
+					Route:     routePattern(request),
+					Path:      request.URL.Path,
+					Status:    wrapped.Status(),
+					Bytes:     wrapped.BytesWritten(),
+					LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+					ClientIP:  ClientIP(request),
+				}
+				//nothing written means the handler returned without writing a status
+				if entry.Status == 0 {
+					entry.Status = http.StatusOK
+				}
+				bytes, _ := json.Marshal(entry)
+				if entry.Status >= http.StatusInternalServerError {
+					log.FormatErrorService(serviceName, "%s", bytes)
+				} else {
+					log.InfoService(serviceName, string(bytes))
+				}
+			}()
+			next.ServeHTTP(wrapped, request)
+		})
+	}
+}
+
+//routePattern returns the route pattern that matched the request, the path if there is none
+func routePattern(request *http.Request) string {
+	if ctx := chi.RouteContext(request.Context()); ctx != nil {
+		if pattern := ctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+
+	return request.URL.Path
+}
+
+//Recoverer returns a middleware that recovers from panics in handlers, the panic and its stack are
+// written to the logger under the service name and the client receives an internal server error
+func Recoverer(log logger.Logger, serviceName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				//the server handles aborted handlers itself
+				if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(recovered)
+				}
+				log.ErrorService(serviceName, ErrHandlerPanicf.Withf(request.Method, request.URL.Path, recovered, debug.Stack()))
+				WriteProblem(writer, request, ErrInternal)
+			}()
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+//Timeout returns a middleware that cancels the context of a request after the timeout and responds
+// with a gateway timeout if the handler didn't return by then, like http.TimeoutHandler the response
+// is buffered until the handler returns and the writes of a handler that timed out fail with
+// http.ErrHandlerTimeout, handlers should watch the context to stop their work, panics are raised
+// again in the goroutine serving the request
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			ctx, cancel := context.WithTimeout(request.Context(), timeout)
+			defer cancel()
+
+			buffered := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if recovered := recover(); recovered != nil {
+						panicked <- recovered
+					}
+				}()
+				next.ServeHTTP(buffered, request.WithContext(ctx))
+				close(done)
+			}()
+			select {
+			case recovered := <-panicked:
+				panic(recovered)
+			case <-done:
+				buffered.flush(writer)
+			case <-ctx.Done():
+				buffered.Lock()
+				defer buffered.Unlock()
+				buffered.timedOut = true
+				//the client is gone if the request was canceled before the timeout
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					WriteProblem(writer, request, ErrRequestTimeout)
+				}
+			}
+		})
+	}
+}
+
+//timeoutWriter buffers the response of a handler run by Timeout
+type timeoutWriter struct {
+	sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+//Header returns the header of the buffered response
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+//Write buffers the body of the response, fails once the handler timed out
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.body.Write(data)
+}
+
+//WriteHeader keeps the status of the response, the first status is kept
+func (w *timeoutWriter) WriteHeader(status int) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.timedOut || w.status != 0 {
+		return
+	}
+	w.status = status
+}
+
+//flush writes the buffered response
+func (w *timeoutWriter) flush(writer http.ResponseWriter) {
+	w.Lock()
+	defer w.Unlock()
+
+	for key, values := range w.header {
+		writer.Header()[key] = values
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	writer.WriteHeader(w.status)
+	writer.Write(w.body.Bytes())
+}
@@ -0,0 +1,229 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	chi "github.com/go-chi/chi"
+	logger "github.com/nationaloilwellvarco/max-edge/lib-logger-go"
+)
+
+//testLog records the entries written by the middlewares, the other methods of the logger aren't used
+type testLog struct {
+	logger.Logger
+	sync.Mutex
+	infos  []string
+	errors []string
+}
+
+func (l *testLog) InfoService(serviceName, content string) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.infos = append(l.infos, content)
+}
+
+func (l *testLog) ErrorService(serviceName string, content error) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.errors = append(l.errors, content.Error())
+}
+
+func (l *testLog) FormatErrorService(serviceName, format string, errs ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.errors = append(l.errors, fmt.Sprintf(format, errs...))
+}
+
+func TestRequestID(t *testing.T) {
+	var served string
+	handler := RequestID(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		served = GetRequestID(request.Context())
+	}))
+
+	for name, test := range map[string]struct {
+		requestID string
+		kept      bool
+	}{
+		"valid":    {"abc-123", true},
+		"missing":  {"", false},
+		"space":    {"abc 123", false},
+		"too long": {strings.Repeat("a", maxRequestIDLength+1), false},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set(HeaderRequestID, test.requestID)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if responded := recorder.Header().Get(HeaderRequestID); responded != served || !validRequestID(served) {
+			t.Errorf("%s request id served as %q and responded as %q", name, served, responded)
+		}
+		if kept := served == test.requestID; kept != test.kept {
+			t.Errorf("%s request id %q served as %q", name, test.requestID, served)
+		}
+	}
+	if requestID := GetRequestID(context.Background()); requestID != "" {
+		t.Errorf("request id %q without middleware", requestID)
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	var served string
+	handler := RealIP(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		served = ClientIP(request)
+	}))
+
+	for name, test := range map[string]struct {
+		realIP, forwardedFor string
+		clientIP             string
+	}{
+		"no header":     {"", "", "192.0.2.1"},
+		"real ip":       {"10.0.0.1", "10.0.0.2", "10.0.0.1"},
+		"forwarded for": {"", "10.0.0.2, 10.0.0.3", "10.0.0.2"},
+		"invalid":       {"not an ip", "", "192.0.2.1"},
+		"ipv6":          {"::1", "", "::1"},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.realIP != "" {
+			request.Header.Set(HeaderRealIP, test.realIP)
+		}
+		if test.forwardedFor != "" {
+			request.Header.Set(HeaderForwardedFor, test.forwardedFor)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+		if served != test.clientIP {
+			t.Errorf("%s client ip served as %q", name, served)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	log := &testLog{}
+	r := chi.NewRouter()
+	r.Use(RequestID, AccessLog(log, "service"))
+	r.Get("/items/{id}", func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte("item"))
+	})
+	r.Get("/failing", func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusBadGateway)
+	})
+	r.Get("/empty", func(writer http.ResponseWriter, request *http.Request) {})
+
+	for _, path := range []string{"/items/1", "/failing", "/empty"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if len(log.infos) != 2 || len(log.errors) != 1 {
+		t.Fatalf("access written as %v and %v", log.infos, log.errors)
+	}
+	var entry AccessEntry
+	if err := json.Unmarshal([]byte(log.infos[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Route != "/items/{id}" || entry.Path != "/items/1" || entry.Status != http.StatusOK || entry.Bytes != 4 || entry.RequestID == "" || entry.ClientIP != "192.0.2.1" {
+		t.Errorf("access written as %+v", entry)
+	}
+	//server errors are written as errors
+	if err := json.Unmarshal([]byte(log.errors[0]), &entry); err != nil || entry.Status != http.StatusBadGateway {
+		t.Errorf("failed access written as %+v, %v", entry, err)
+	}
+	//a handler that doesn't write responds ok
+	if err := json.Unmarshal([]byte(log.infos[1]), &entry); err != nil || entry.Status != http.StatusOK {
+		t.Errorf("empty access written as %+v, %v", entry, err)
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+	log := &testLog{}
+	handler := Recoverer(log, "service")(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		panic("broken handler")
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/broken", nil))
+	if recorder.Code != http.StatusInternalServerError || recorder.Header().Get("Content-Type") != ContentTypeProblem {
+		t.Errorf("panic responded %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	if len(log.errors) != 1 || !strings.Contains(log.errors[0], "broken handler") || !strings.Contains(log.errors[0], "/broken") {
+		t.Errorf("panic written as %v", log.errors)
+	}
+	//aborted handlers are left to the server
+	aborted := Recoverer(log, "service")(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("aborted handler recovered as %v", recovered)
+		}
+	}()
+	aborted.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestTimeout(t *testing.T) {
+	handler := Timeout(50 * time.Millisecond)(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("X-Handler", "served")
+		writer.WriteHeader(http.StatusCreated)
+		writer.Write([]byte("created"))
+	}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusCreated || recorder.Header().Get("X-Handler") != "served" || recorder.Body.String() != "created" {
+		t.Errorf("handler responded %d %v %q", recorder.Code, recorder.Header(), recorder.Body.String())
+	}
+
+	//a handler ignoring its context doesn't hold the response
+	written := make(chan error, 1)
+	release := make(chan struct{})
+	handler = Timeout(50 * time.Millisecond)(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-release
+		_, err := writer.Write([]byte("late"))
+		written <- err
+	}))
+	recorder = httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("timed out after %v", elapsed)
+	}
+	close(release)
+	if err := <-written; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("late write returned %v", err)
+	}
+	if recorder.Code != http.StatusGatewayTimeout || strings.Contains(recorder.Body.String(), "late") {
+		t.Errorf("timed out handler responded %d %q", recorder.Code, recorder.Body.String())
+	}
+
+	//panics are raised in the goroutine of the request so that Recoverer handles them
+	log := &testLog{}
+	handler = Recoverer(log, "service")(Timeout(time.Second)(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		panic("broken handler")
+	})))
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusInternalServerError || len(log.errors) != 1 {
+		t.Errorf("panic responded %d and written as %v", recorder.Code, log.errors)
+	}
+}
+
+func TestTimeoutCanceled(t *testing.T) {
+	handler := Timeout(time.Second)(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-request.Context().Done()
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	//nothing is written to a client that is gone
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	if recorder.Body.Len() != 0 || recorder.Code != http.StatusOK {
+		t.Errorf("canceled request responded %d %q", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,207 @@
+package router
+
+//---------------------------------------------------------------------------------------------------
+// mtls.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//mtls constants
+const (
+	clientIdentityContextKey ctxKey = "clientIdentity"
+	wildcardPrefix           string = "*."
+)
+
+//ClientIdentity is the identity of the verified certificate of a client
+type ClientIdentity struct {
+	CommonName     string   `json:"commonName"`
+	Subject        string   `json:"subject"` //distinguished name of the subject
+	DNSNames       []string `json:"dnsNames,omitempty"`
+	URIs           []string `json:"uris,omitempty"` //e.g. SPIFFE ids
+	EmailAddresses []string `json:"emailAddresses,omitempty"`
+	SerialNumber   string   `json:"serialNumber"`
+	Fingerprint    string   `json:"fingerprint"` //hex encoded SHA-256 of the certificate
+}
+
+//Names returns every name of the identity, the common name followed by the subject alternative names
+func (c *ClientIdentity) Names() (names []string) {
+	if c.CommonName != "" {
+		names = append(names, c.CommonName)
+	}
+	names = append(names, c.DNSNames...)
+	names = append(names, c.URIs...)
+	names = append(names, c.EmailAddresses...)
+
+	return
+}
+
+//Matches returns whether or not the identity has a name matching the pattern, a pattern starting
+// with *. matches the host names, i.e. the common name and the DNS names, of a single level below
+// the domain, e.g. *.edge.local matches a.edge.local but not a.b.edge.local, URIs and email
+// addresses only match exactly
+func (c *ClientIdentity) Matches(pattern string) bool {
+	for _, name := range c.Names() {
+		if name == pattern {
+			return true
+		}
+	}
+	if !strings.HasPrefix(pattern, wildcardPrefix) {
+		return false
+	}
+	hostNames := c.DNSNames
+	if c.CommonName != "" {
+		hostNames = append([]string{c.CommonName}, hostNames...)
+	}
+	for _, name := range hostNames {
+		label, domain, found := strings.Cut(name, ".")
+		//a label can't hide a scheme, a port or a user, e.g. https://a.edge.local or a@b.edge.local
+		if found && label != "" && !strings.ContainsAny(label, "@:/") && "."+domain == pattern[1:] {
+			return true
+		}
+	}
+
+	return false
+}
+
+//newClientIdentity returns the identity of a certificate
+func newClientIdentity(certificate *x509.Certificate) (identity *ClientIdentity) {
+	fingerprint := sha256.Sum256(certificate.Raw)
+	identity = &ClientIdentity{
+		CommonName:     certificate.Subject.CommonName,
+		Subject:        certificate.Subject.String(),
+		DNSNames:       certificate.DNSNames,
+		EmailAddresses: certificate.EmailAddresses,
+		SerialNumber:   certificate.SerialNumber.String(),
+		Fingerprint:    hex.EncodeToString(fingerprint[:]),
+	}
+	for _, uri := range certificate.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+
+	return
+}
+
+//clientIdentity returns the identity of the verified certificate of the client of a request, the
+// certificates of clients that weren't verified against the client CAs are ignored
+func clientIdentity(request *http.Request) (identity *ClientIdentity, ok bool) {
+	if identity, ok = GetClientIdentity(request.Context()); ok {
+		return
+	}
+	if request.TLS == nil || len(request.TLS.VerifiedChains) == 0 || len(request.TLS.VerifiedChains[0]) == 0 {
+		return
+	}
+
+	return newClientIdentity(request.TLS.VerifiedChains[0][0]), true
+}
+
+//ClientIdentityMiddleware is a middleware that places the identity of the verified client certificate
+// in the request context, requests without a verified certificate are served without identity
+func ClientIdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if identity, ok := clientIdentity(request); ok {
+			request = request.WithContext(context.WithValue(request.Context(), clientIdentityContextKey, identity))
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+//GetClientIdentity can be used to get the identity placed in the request context by
+// ClientIdentityMiddleware
+func GetClientIdentity(ctx context.Context) (identity *ClientIdentity, ok bool) {
+	if ctx != nil {
+		identity, ok = ctx.Value(clientIdentityContextKey).(*ClientIdentity)
+	}
+
+	return
+}
+
+//AllowClients returns a middleware that only serves clients with a verified certificate matching one
+// of the patterns, see ClientIdentity.Matches, requests without a verified certificate are
+// unauthorized and clients that don't match are forbidden
+func AllowClients(patterns ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			identity, ok := clientIdentity(request)
+			if !ok {
+				WriteProblem(writer, request, ErrClientCertMissing)
+				return
+			}
+			for _, pattern := range patterns {
+				if identity.Matches(pattern) {
+					next.ServeHTTP(writer, request)
+					return
+				}
+			}
+			WriteProblem(writer, request, ErrClientNotAllowedf.Withf(identity.Subject))
+		})
+	}
+}
+
+//loadCertPool reads the PEM certificates of a bundle
+func loadCertPool(caFile string) (pool *x509.CertPool, cas []*x509.Certificate, err error) {
+	bytes, err := os.ReadFile(caFile)
+	if err != nil {
+		err = ErrClientCAf.Withf(caFile).Wrap(err)
+
+		return
+	}
+	pool = x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bytes) {
+		pool, err = nil, ErrClientCAf.Withf(caFile)
+
+		return
+	}
+	cas = loadCAs(bytes)
+
+	return
+}
+
+//SetClientAuth is used to authenticate clients with certificates signed by the CAs of the bundle, the
+// mode tells whether certificates are required, e.g. tls.RequireAndVerifyClientCert, or only
+// verified if given, e.g. tls.VerifyClientCertIfGiven, security must be set as well
+func (r *Router) SetClientAuth(caFile string, mode tls.ClientAuthType) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.started {
+		err = ErrRouterStarted
+
+		return
+	}
+	pool, _, err := loadCertPool(caFile)
+	if err != nil {
+		return
+	}
+	r.clientCAFile, r.clientCAs, r.clientAuth = caFile, pool, mode
+
+	return
+}
+
+//serverTLSConfig returns the TLS configuration of the server, the configuration given to SetSecurity
+// with the client CAs and mode given to SetClientAuth
+func (r *Router) serverTLSConfig() (tlsConfig *tls.Config, err error) {
+	if r.clientCAs == nil {
+		return r.tlsConfig, nil
+	}
+	if !r.usingSecurity {
+		err = ErrClientAuthNoTLS
+
+		return
+	}
+	tlsConfig = &tls.Config{}
+	if r.tlsConfig != nil {
+		tlsConfig = r.tlsConfig.Clone()
+	}
+	tlsConfig.ClientCAs, tlsConfig.ClientAuth = r.clientCAs, r.clientAuth
+
+	return
+}
@@ -0,0 +1,144 @@
+package router
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+//newTestCertificate creates a certificate of the common name and DNS names signed by the parent, the
+// certificate is self-signed if the parent is nil
+func newTestCertificate(t *testing.T, commonName string, dnsNames []string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              dnsNames,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate, key
+}
+
+func TestAllowClients(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "ca", nil, nil, nil)
+	allowed, _ := newTestCertificate(t, "sensor", []string{"sensor.edge.local"}, ca, caKey)
+	other, _ := newTestCertificate(t, "other", []string{"other.site.local"}, ca, caKey)
+	handler := AllowClients("*.edge.local")(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	serve := func(state *tls.ConnectionState) int {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.TLS = state
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		return recorder.Code
+	}
+
+	if status := serve(&tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{allowed, ca}}}); status != http.StatusOK {
+		t.Errorf("allowed client served with %d", status)
+	}
+	if status := serve(&tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{other, ca}}}); status != http.StatusForbidden {
+		t.Errorf("client not allowed served with %d", status)
+	}
+	//wildcards only match host names
+	email, uri, commonName := *other, *other, *other
+	email.EmailAddresses = []string{"anyone@x.edge.local"}
+	uri.URIs = []*url.URL{{Scheme: "https", Host: "x.edge.local"}}
+	commonName.Subject.CommonName = "anyone@x.edge.local"
+	for name, certificate := range map[string]*x509.Certificate{"email": &email, "uri": &uri, "common name": &commonName} {
+		if status := serve(&tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{certificate, ca}}}); status != http.StatusForbidden {
+			t.Errorf("client with a matching %s served with %d", name, status)
+		}
+	}
+	//clients fail closed without a verified certificate
+	if status := serve(nil); status != http.StatusUnauthorized {
+		t.Errorf("request without TLS served with %d", status)
+	}
+	if status := serve(&tls.ConnectionState{}); status != http.StatusUnauthorized {
+		t.Errorf("request without certificate served with %d", status)
+	}
+	if status := serve(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{allowed}}); status != http.StatusUnauthorized {
+		t.Errorf("request with an unverified certificate served with %d", status)
+	}
+}
+
+func TestAllowClientsRoute(t *testing.T) {
+	r := NewRouter(nil)
+	route := RouteConfiguration{
+		Route:          "/clients",
+		Method:         http.MethodGet,
+		HandleFx:       func(http.ResponseWriter, *http.Request) {},
+		AllowedClients: []string{"sensor"},
+	}
+
+	r.Lock()
+	r.buildRoutes([]RouteConfiguration{route})
+	err := r.rebuild()
+	r.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	r.serveHTTP(recorder, httptest.NewRequest(http.MethodGet, "/clients", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("route allowing clients served a request without certificate with %d", recorder.Code)
+	}
+}
+
+func TestClientIdentityMatches(t *testing.T) {
+	identity := &ClientIdentity{
+		CommonName:     "sensor",
+		DNSNames:       []string{"sensor.edge.local"},
+		URIs:           []string{"spiffe://edge.local/sensor"},
+		EmailAddresses: []string{"ops@edge.local"},
+	}
+
+	for pattern, matches := range map[string]bool{
+		"sensor":                     true,
+		"sensor.edge.local":          true,
+		"*.edge.local":               true,
+		"spiffe://edge.local/sensor": true,
+		"ops@edge.local":             true,
+		"*.local":                    false,
+		"*.sensor.edge.local":        false,
+		"other":                      false,
+	} {
+		if identity.Matches(pattern) != matches {
+			t.Errorf("pattern %s matched is %t", pattern, !matches)
+		}
+	}
+}
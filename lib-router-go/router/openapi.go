@@ -0,0 +1,360 @@
+package router
+
+//---------------------------------------------------------------------------------------------------
+// openapi.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	chi "github.com/go-chi/chi"
+)
+
+//openapi constants
+const (
+	OpenAPIVersion     string = "3.0.3"
+	OpenAPIPath        string = "/openapi.json"
+	DefaultDocsRoute   string = "/docs"
+	ParameterInPath    string = "path"
+	ParameterInQuery   string = "query"
+	schemaRefPrefix    string = "#/components/schemas/"
+	contentTypeJSON    string = "application/json"
+	responseDefault    string = "default"
+	responseDefaultDoc string = "Error"
+)
+
+//docsHTML is the page of the docs ui, it renders the OpenAPI document served by the router
+//
+//go:embed docs.html
+var docsHTML string
+
+//docsTemplate is the template of the docs ui
+var docsTemplate = template.Must(template.New("docs").Parse(docsHTML))
+
+//OpenAPIInfo describes the API in the OpenAPI document
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+//OpenAPIDocument is an OpenAPI 3 document
+type OpenAPIDocument struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       OpenAPIInfo         `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components OpenAPIComponents   `json:"components"`
+}
+
+//OpenAPIComponents holds the schemas referenced by the operations
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+//PathItem holds the operations of a path by lower case method
+type PathItem map[string]*Operation
+
+//Operation describes a route
+type Operation struct {
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+//Parameter describes a path or query parameter of an operation
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+//RequestBody describes the body of a request
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+//Response describes a response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+//MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+//Schema is the json schema of a type
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+//schemaBuilder creates the schemas of types, named structs are added to the components and referenced
+type schemaBuilder struct {
+	schemas map[string]*Schema //schemas of the named structs by name
+}
+
+//GenerateOpenAPI creates the OpenAPI document of routes, the path variables of a route are its
+// path parameters and are documented by the parameters of the route with the same name
+func GenerateOpenAPI(info OpenAPIInfo, routes []RouteConfiguration) (document OpenAPIDocument) {
+	builder := &schemaBuilder{schemas: make(map[string]*Schema)}
+	document = OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+	}
+	for _, route := range routes {
+		path := openAPIPath(route.Route)
+		if _, ok := document.Paths[path]; !ok {
+			document.Paths[path] = make(PathItem)
+		}
+		document.Paths[path][strings.ToLower(route.Method)] = builder.operation(route)
+	}
+	document.Components.Schemas = builder.schemas
+
+	return
+}
+
+//openAPIPath removes the regular expressions of the variables of a route, e.g. /items/{id:[0-9]+}
+// becomes /items/{id}
+func openAPIPath(route string) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = "{" + RouteVariables(segment)[0] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+//operation describes a route
+func (b *schemaBuilder) operation(route RouteConfiguration) (operation *Operation) {
+	operation = &Operation{
+		Summary:     route.Summary,
+		Description: route.Description,
+		Tags:        route.Tags,
+		Responses:   make(map[string]Response),
+	}
+	//path parameters are the variables of the route
+	for _, name := range RouteVariables(route.Route) {
+		parameter := Parameter{Name: name, In: ParameterInPath, Required: true, Schema: &Schema{Type: "string"}}
+		for _, doc := range route.Parameters {
+			if doc.In == ParameterInPath && doc.Name == name {
+				parameter.Description = doc.Description
+				parameter.Schema = parameterSchema(doc)
+			}
+		}
+		operation.Parameters = append(operation.Parameters, parameter)
+	}
+	for _, doc := range route.Parameters {
+		if doc.In == ParameterInQuery {
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name:        doc.Name,
+				In:          ParameterInQuery,
+				Description: doc.Description,
+				Required:    doc.Required,
+				Schema:      parameterSchema(doc),
+			})
+		}
+	}
+	//bodies
+	if route.Request != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{contentTypeJSON: {Schema: b.schema(reflect.TypeOf(route.Request))}},
+		}
+	}
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := Response{Description: http.StatusText(status)}
+	if route.Response != nil {
+		response.Content = map[string]MediaType{contentTypeJSON: {Schema: b.schema(reflect.TypeOf(route.Response))}}
+	}
+	operation.Responses[strconv.Itoa(status)] = response
+	operation.Responses[responseDefault] = Response{
+		Description: responseDefaultDoc,
+		Content:     map[string]MediaType{ContentTypeProblem: {Schema: b.schema(reflect.TypeOf(Problem{}))}},
+	}
+
+	return
+}
+
+//parameterSchema returns the schema of a parameter
+func parameterSchema(doc ParameterDoc) *Schema {
+	if doc.Type == "" {
+		return &Schema{Type: "string"}
+	}
+
+	return &Schema{Type: doc.Type}
+}
+
+//schema returns the schema of a type, named structs are referenced
+func (b *schemaBuilder) schema(t reflect.Type) *Schema {
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := b.schema(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		//bytes are encoded in base64
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := b.schemas[name]; !ok {
+			//registered before its fields so that recursive types end
+			b.schemas[name] = &Schema{}
+			*b.schemas[name] = *b.structSchema(t)
+		}
+		return &Schema{Ref: schemaRefPrefix + name}
+	default:
+		//interfaces can hold anything
+		return &Schema{}
+	}
+}
+
+//structSchema returns the schema of the fields of a struct as encoded by the json package, fields
+// without omitempty are required
+func (b *schemaBuilder) structSchema(t reflect.Type) (schema *Schema) {
+	schema = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+		name, options := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, options = tag[:i], tag[i+1:]
+		}
+		//the fields of embedded structs without a name are promoted
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			embedded := b.structSchema(fieldType)
+			for property, propertySchema := range embedded.Properties {
+				schema.Properties[property] = propertySchema
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = b.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return
+}
+
+//schemaName returns the name of a type in the components, the package qualifies it so that types
+// with the same name don't collide
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if pkg := t.PkgPath(); pkg != "" {
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+
+	return strings.NewReplacer("[", "_", "]", "", "/", "_", "*", "", " ", "").Replace(name)
+}
+
+//SetOpenAPI adds a route responding with the OpenAPI document of the routes of the router, the
+// document follows the routes as they're added or removed, the docs ui is served on the docs route,
+// e.g. DefaultDocsRoute, unless it's empty
+func (r *Router) SetOpenAPI(info OpenAPIInfo, docsRoute string) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.started {
+		err = ErrRouterStarted
+
+		return
+	}
+	r.openAPI, r.docsRoute = &info, docsRoute
+
+	return
+}
+
+//buildOpenAPI adds the routes of the OpenAPI document and the docs ui to a router
+func (r *Router) buildOpenAPI(router chi.Router, routes []RouteConfiguration, groups []RouteGroup) (err error) {
+	groupRoutes, _ := FlattenGroups(groups)
+	document := GenerateOpenAPI(*r.openAPI, append(append([]RouteConfiguration(nil), routes...), groupRoutes...))
+	bytes, err := json.Marshal(document)
+	if err != nil {
+		return
+	}
+	router.MethodFunc(http.MethodGet, OpenAPIPath, func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", contentTypeJSON)
+		writer.Write(bytes)
+	})
+	if r.docsRoute != "" {
+		title := r.openAPI.Title
+		router.MethodFunc(http.MethodGet, r.docsRoute, func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+			docsTemplate.Execute(writer, struct {
+				Title   string
+				SpecURL string
+			}{Title: title, SpecURL: OpenAPIPath})
+		})
+	}
+
+	return
+}
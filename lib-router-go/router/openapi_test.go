@@ -0,0 +1,220 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testEmbedded struct {
+	Created time.Time `json:"created"`
+}
+
+//testNode is a recursive type with every kind of field
+type testNode struct {
+	testEmbedded
+	Name     string           `json:"name"`
+	Parent   *testNode        `json:"parent,omitempty"`
+	Children []testNode       `json:"children"`
+	Label    *string          `json:"label"`
+	Data     []byte           `json:"data"`
+	Counts   map[string]int32 `json:"counts"`
+	Ratio    float64          `json:"ratio"`
+	Enabled  bool             `json:"enabled,omitempty"`
+	Extra    interface{}      `json:"extra"`
+	Raw      json.RawMessage  `json:"raw"`
+	Inline   struct{ ID int } `json:"inline"`
+	Ignored  string           `json:"-"`
+	Untagged uint8
+	hidden   string
+}
+
+func TestSchemaBuilder(t *testing.T) {
+	b := &schemaBuilder{schemas: make(map[string]*Schema)}
+
+	if schema := b.schema(reflect.TypeOf(testNode{})); schema.Ref != schemaRefPrefix+"router.testNode" {
+		t.Fatalf("named struct is %+v", schema)
+	}
+	schema := b.schemas["router.testNode"]
+	if schema == nil || schema.Type != "object" || len(b.schemas) != 1 {
+		t.Fatalf("components are %+v", b.schemas)
+	}
+	for name, want := range map[string]Schema{
+		"created":  {Type: "string", Format: "date-time"},
+		"name":     {Type: "string"},
+		"parent":   {Ref: schemaRefPrefix + "router.testNode"},
+		"label":    {Type: "string", Nullable: true},
+		"data":     {Type: "string", Format: "byte"},
+		"ratio":    {Type: "number", Format: "double"},
+		"enabled":  {Type: "boolean"},
+		"extra":    {},
+		"raw":      {},
+		"Untagged": {Type: "integer", Format: "int32"},
+	} {
+		if property := schema.Properties[name]; property == nil || !reflect.DeepEqual(*property, want) {
+			t.Errorf("%s property is %+v", name, property)
+		}
+	}
+	if children := schema.Properties["children"]; children.Type != "array" || children.Items.Ref != schemaRefPrefix+"router.testNode" {
+		t.Errorf("children property is %+v", children)
+	}
+	if counts := schema.Properties["counts"]; counts.Type != "object" || counts.AdditionalProperties.Format != "int32" {
+		t.Errorf("counts property is %+v", counts)
+	}
+	//anonymous structs aren't part of the components
+	if inline := schema.Properties["inline"]; inline.Ref != "" || inline.Properties["ID"].Type != "integer" {
+		t.Errorf("inline property is %+v", inline)
+	}
+	for _, name := range []string{"Ignored", "hidden", "testEmbedded"} {
+		if _, ok := schema.Properties[name]; ok {
+			t.Errorf("%s property documented", name)
+		}
+	}
+	//fields without omitempty are required
+	required := strings.Join(schema.Required, ",")
+	if !strings.Contains(required, "created") || !strings.Contains(required, "name") || strings.Contains(required, "parent") || strings.Contains(required, "enabled") {
+		t.Errorf("required properties are %v", schema.Required)
+	}
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	document := GenerateOpenAPI(OpenAPIInfo{Title: "Items", Version: "1.0"}, []RouteConfiguration{
+		{
+			Route:      "/items/{id:[0-9]+}",
+			Method:     http.MethodGet,
+			Summary:    "Get an item",
+			Tags:       []string{"items"},
+			Response:   testResponse{},
+			Parameters: []ParameterDoc{{Name: "id", In: ParameterInPath, Description: "item id", Type: "integer"}},
+		},
+		{
+			Route:      "/items",
+			Method:     http.MethodPost,
+			Request:    testRequest{},
+			Response:   &testResponse{},
+			Status:     http.StatusCreated,
+			Parameters: []ParameterDoc{{Name: "limit", In: ParameterInQuery, Required: true}},
+		},
+		{Route: "/items/{id}", Method: http.MethodDelete, Status: http.StatusNoContent},
+	})
+
+	if document.OpenAPI != OpenAPIVersion || document.Info.Title != "Items" || len(document.Paths) != 2 {
+		t.Fatalf("document is %+v", document)
+	}
+	//the regular expressions of the variables aren't part of the paths
+	item := document.Paths["/items/{id}"]
+	if len(item) != 2 || item["get"] == nil || item["delete"] == nil {
+		t.Fatalf("item operations are %+v", item)
+	}
+	get := item["get"]
+	if get.Summary != "Get an item" || len(get.Tags) != 1 || len(get.Parameters) != 1 {
+		t.Errorf("get operation is %+v", get)
+	}
+	if parameter := get.Parameters[0]; parameter.In != ParameterInPath || !parameter.Required || parameter.Description != "item id" || parameter.Schema.Type != "integer" {
+		t.Errorf("path parameter is %+v", parameter)
+	}
+	if response := get.Responses["200"]; response.Content[contentTypeJSON].Schema.Ref != schemaRefPrefix+"router.testResponse" {
+		t.Errorf("get response is %+v", response)
+	}
+	//errors are problems
+	if response := get.Responses[responseDefault]; response.Content[ContentTypeProblem].Schema.Ref != schemaRefPrefix+"router.Problem" {
+		t.Errorf("default response is %+v", response)
+	}
+	//path parameters without documentation are strings
+	if parameters := item["delete"].Parameters; len(parameters) != 1 || parameters[0].Schema.Type != "string" {
+		t.Errorf("delete parameters are %+v", parameters)
+	}
+	if response, ok := item["delete"].Responses["204"]; !ok || response.Content != nil {
+		t.Errorf("delete responses are %+v", item["delete"].Responses)
+	}
+	post := document.Paths["/items"]["post"]
+	if post == nil || post.RequestBody == nil || post.RequestBody.Content[contentTypeJSON].Schema.Ref != schemaRefPrefix+"router.testRequest" {
+		t.Fatalf("post operation is %+v", post)
+	}
+	if parameters := post.Parameters; len(parameters) != 1 || parameters[0].In != ParameterInQuery || !parameters[0].Required {
+		t.Errorf("query parameters are %+v", parameters)
+	}
+	if response := post.Responses["201"]; response.Content[contentTypeJSON].Schema.Ref != schemaRefPrefix+"router.testResponse" {
+		t.Errorf("post response is %+v", response)
+	}
+	for _, name := range []string{"router.testRequest", "router.testResponse", "router.Problem"} {
+		if document.Components.Schemas[name] == nil {
+			t.Errorf("%s schema isn't a component", name)
+		}
+	}
+	if _, err := json.Marshal(document); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	r := NewRouter(nil)
+	if err := r.SetOpenAPI(OpenAPIInfo{Title: "<Items>", Version: "1.0"}, DefaultDocsRoute); err != nil {
+		t.Fatal(err)
+	}
+	r.Lock()
+	r.buildRoutes([]RouteConfiguration{{Route: "/items", Method: http.MethodGet, HandleFx: noContent}})
+	r.groups["/api"] = testGroups()[0]
+	err := r.rebuild()
+	r.started = true
+	r.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.SetOpenAPI(OpenAPIInfo{}, ""); !errors.Is(err, ErrRouterStarted) {
+		t.Errorf("OpenAPI set with %v once started", err)
+	}
+	//the document follows the routes and holds the routes of the groups
+	if err = r.AddRoutes(RouteConfiguration{Route: "/added", Method: http.MethodPost, HandleFx: noContent}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	r.serveHTTP(recorder, httptest.NewRequest(http.MethodGet, OpenAPIPath, nil))
+	var document OpenAPIDocument
+	if err = json.NewDecoder(recorder.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Header().Get("Content-Type") != contentTypeJSON || document.Info.Title != "<Items>" {
+		t.Errorf("document responded as %v %+v", recorder.Header(), document.Info)
+	}
+	for _, path := range []string{"/items", "/added", "/api/", "/api/items/{id}", "/api/v1/status"} {
+		if _, ok := document.Paths[path]; !ok {
+			t.Errorf("%s isn't documented: %v", path, document.Paths)
+		}
+	}
+	//the docs page renders the document
+	recorder = httptest.NewRecorder()
+	r.serveHTTP(recorder, httptest.NewRequest(http.MethodGet, DefaultDocsRoute, nil))
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("docs responded %d %v", recorder.Code, recorder.Header())
+	}
+	if !strings.Contains(body, "<title>&lt;Items&gt;</title>") || !strings.Contains(body, "openapi.json") {
+		t.Errorf("docs responded %s", body)
+	}
+}
+
+func TestOpenAPIWithoutDocs(t *testing.T) {
+	r := NewRouter(nil)
+	if err := r.SetOpenAPI(OpenAPIInfo{Title: "Items"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	r.Lock()
+	err := r.rebuild()
+	r.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := serveStatus(r.serveHTTP, http.MethodGet, OpenAPIPath); status != http.StatusOK {
+		t.Errorf("document responded %d", status)
+	}
+	if status := serveStatus(r.serveHTTP, http.MethodGet, DefaultDocsRoute); status != http.StatusNotFound {
+		t.Errorf("docs responded %d without docs route", status)
+	}
+}
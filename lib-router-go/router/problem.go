@@ -0,0 +1,158 @@
+package router
+
+//---------------------------------------------------------------------------------------------------
+// problem.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	catalog "github.com/nationaloilwellvarco/max-edge/lib-errors-go/catalog"
+)
+
+//problem constants
+const (
+	ContentTypeProblem string = "application/problem+json"
+	ProblemTypeBlank   string = "about:blank" //type of problems without a code
+)
+
+//ProblemTypeBase is prepended to the code of an error to create the type of its problem, e.g.
+// https://errors.example.com/ for https://errors.example.com/RTR-010, problems are typed about:blank
+// if empty
+var ProblemTypeBase = ""
+
+//Problem is the body of error responses as described by RFC 7807, every error written by the router
+// is a problem so that clients can parse them the same way
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`  //path of the request
+	Code      string       `json:"code,omitempty"`      //code of the error in the error catalog
+	RequestID string       `json:"requestId,omitempty"` //id given by the RequestID middleware
+	Errors    []FieldError `json:"errors,omitempty"`    //errors of the fields of the request
+}
+
+//FieldError is the error of a field of a request
+type FieldError struct {
+	Field   string `json:"field"`          //name of the field, e.g. items[0].name
+	Code    string `json:"code,omitempty"` //code of the error, e.g. required
+	Message string `json:"message"`
+}
+
+//FieldErrors can be returned by validation to report the errors of every field, they're written as
+// the errors of the problem
+type FieldErrors []FieldError
+
+//Error joins the errors of the fields
+func (f FieldErrors) Error() string {
+	messages := make([]string, 0, len(f))
+	for _, fieldError := range f {
+		messages = append(messages, fieldError.Field+": "+fieldError.Message)
+	}
+
+	return strings.Join(messages, ", ")
+}
+
+//NewProblem creates the problem of an error, the status, title and detail are given by the error
+// catalog, errors that aren't part of the catalog are internal server errors without detail so
+// that they don't leak, internal and unavailable errors are detailed by their message only since
+// the errors they wrap may leak, the request may be nil
+func NewProblem(request *http.Request, err error) (problem Problem) {
+	var fieldErrors FieldErrors
+
+	problem = Problem{
+		Type:   ProblemTypeBlank,
+		Status: catalog.HTTPStatus(err),
+		Code:   catalog.CodeOf(err),
+	}
+	problem.Title = http.StatusText(problem.Status)
+	if catalogErr, ok := catalog.As(err); ok {
+		switch catalogErr.Category {
+		case catalog.CategoryInternal, catalog.CategoryUnavailable:
+			problem.Detail = catalogErr.Message
+		default:
+			problem.Detail = err.Error()
+		}
+		if ProblemTypeBase != "" {
+			problem.Type = ProblemTypeBase + problem.Code
+		}
+	}
+	if errors.As(err, &fieldErrors) {
+		problem.Errors = fieldErrors
+	}
+	if request != nil {
+		problem.Instance = request.URL.Path
+		problem.RequestID = GetRequestID(request.Context())
+	}
+
+	return
+}
+
+//WriteProblem can be used by handle functions to respond with the problem of an error
+func WriteProblem(writer http.ResponseWriter, request *http.Request, err error) {
+	problem := NewProblem(request, err)
+	writer.Header().Set("Content-Type", ContentTypeProblem)
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	writer.WriteHeader(problem.Status)
+	json.NewEncoder(writer).Encode(problem)
+}
+
+//NotFound responds with the problem of a path that doesn't match any route
+func NotFound(writer http.ResponseWriter, request *http.Request) {
+	WriteProblem(writer, request, ErrPathNotFoundf.Withf(request.URL.Path))
+}
+
+//MethodNotAllowed responds with the problem of a method that doesn't match any route of the path
+func MethodNotAllowed(writer http.ResponseWriter, request *http.Request) {
+	WriteProblem(writer, request, ErrMethodNotAllowedf.Withf(request.Method, request.URL.Path))
+}
+
+//BodyLimit returns a middleware that limits the size of request bodies, requests declaring a larger
+// body are rejected and reading past the limit fails
+func BodyLimit(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.ContentLength > limit {
+				WriteProblem(writer, request, ErrBodyTooLargef.Withf(limit))
+				return
+			}
+			if request.Body != nil && request.Body != http.NoBody {
+				request.Body = &limitedBody{ReadCloser: request.Body, remaining: limit, limit: limit}
+			}
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+//limitedBody is a body that fails once more than the limit is read
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64 //bytes that can still be read
+	limit     int64
+}
+
+func (b *limitedBody) Read(p []byte) (n int, err error) {
+	if b.remaining < 0 {
+		err = ErrBodyTooLargef.Withf(b.limit)
+
+		return
+	}
+	//read one byte more than what remains to know whether or not the body is larger
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err = b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		n += int(b.remaining)
+		err = ErrBodyTooLargef.Withf(b.limit)
+	}
+
+	return
+}
@@ -0,0 +1,56 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblemDetail(t *testing.T) {
+	cause := errors.New("dial tcp 10.0.0.1:5432: connection refused")
+
+	if problem := NewProblem(nil, ErrInternal.Wrap(cause)); problem.Detail != ErrInternal.Message {
+		t.Errorf("internal error detailed as %q", problem.Detail)
+	}
+	if problem := NewProblem(nil, cause); problem.Detail != "" || problem.Status != http.StatusInternalServerError {
+		t.Errorf("error outside the catalog written as %d %q", problem.Status, problem.Detail)
+	}
+	if problem := NewProblem(nil, ErrDecodeBody.Wrap(errors.New("unexpected EOF"))); problem.Detail != ErrDecodeBody.Message+": unexpected EOF" {
+		t.Errorf("invalid request detailed as %q", problem.Detail)
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	handler := BodyLimit(16)(http.HandlerFunc(JSON(func(ctx context.Context, request testRequest) (testResponse, error) {
+		return testResponse{Name: request.Name}, nil
+	})))
+	serve := func(body io.Reader, contentLength int64) int {
+		request := httptest.NewRequest(http.MethodPost, "/test", body)
+		request.ContentLength = contentLength
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		return recorder.Code
+	}
+
+	if status := serve(strings.NewReader(`{"name":"a"}`), -1); status != http.StatusOK {
+		t.Errorf("body within the limit served with %d", status)
+	}
+	large := `{"name":"` + strings.Repeat("a", 32) + `"}`
+	if status := serve(strings.NewReader(large), int64(len(large))); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("body declared larger than the limit served with %d", status)
+	}
+	//the length of a streamed body is unknown until it's read
+	if status := serve(io.MultiReader(strings.NewReader(large)), -1); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("streamed body larger than the limit served with %d", status)
+	}
+	//data past the limit after a valid json value is read as well
+	trailing := `{"name":"a"}` + strings.Repeat(" ", 32)
+	if status := serve(io.MultiReader(strings.NewReader(trailing)), -1); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("trailing data past the limit served with %d", status)
+	}
+}
@@ -7,10 +7,11 @@ package router
 import (
 	"context"
 	"crypto/tls"
-	"errors"
+	"crypto/x509"
 	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	chi "github.com/go-chi/chi"
@@ -29,6 +30,16 @@ type Router interface {
 	SetSecurity(certfile, keyfile string, tlsConfig *tls.Config) error
 	Close() error
 	LaunchServer()
+	AddRoutes(routes ...RouteConfiguration) error
+	RemoveRoute(method, route string) error
+	ReplaceHandle(handle HandleConfiguration) error
+	AddGroups(groups ...RouteGroup) error
+	RemoveGroup(prefix string) error
+	SetHealthChecker(checker *HealthChecker) error
+	SetMetrics(metrics *Metrics, route string) error
+	SetOpenAPI(info OpenAPIInfo, docsRoute string) error
+	SetAuthenticator(authenticator *Authenticator) error
+	SetClientAuth(caFile string, mode tls.ClientAuthType) error
 }
 
 //Router provide a struct that can house the http rest server, the mux router and be used
@@ -38,25 +49,41 @@ type router struct {
 	sync.WaitGroup                                   //waitgroup to track active goRoutines
 	started        bool                              //whether or not the router has started
 	router         *chi.Mux                          //router for the http.Server
+	mux            atomic.Value                      //holds the *chi.Mux serving requests, swapped when the routes change
 	restServer     *http.Server                      //the rest server
 	log            *log.Logger                       //logger to print to
 	routes         map[string]RouteConfiguration     //routes that have been added
 	handles        map[string]HandleConfiguration    //handles that have been added
+	groups         map[string]RouteGroup             //groups that have been added, by prefix
 	usingSecurity  bool                              //Flag to check for security
 	certfile       string                            //Path to cert file
 	keyfile        string                            //Path to key file
 	tlsConfig      *tls.Config                       //TLS configuration for when using security
 	middlewares    []func(http.Handler) http.Handler //middlewares used by the router
+	healthChecker  *HealthChecker                    //checks run by the ready handle, no health handles if nil
+	readyState     int32                             //whether or not the router is ready, accessed atomically
+	metrics        *Metrics                          //metrics of the requests served, not recorded if nil
+	metricsRoute   string                            //route responding with the metrics
+	openAPI        *OpenAPIInfo                      //info of the OpenAPI document, not served if nil
+	docsRoute      string                            //route of the docs ui, not served if empty
+	authenticator  *Authenticator                    //authenticator enforcing the scopes and roles of routes
+	clientCAFile   string                            //path to the bundle of client CAs
+	clientCAs      *x509.CertPool                    //CAs client certificates are verified against, no client auth if nil
+	clientAuth     tls.ClientAuthType                //whether client certificates are required or verified if given
+	certReloader   *certReloader                     //serves the certificate and client CAs reloaded from their files
 }
 
 //NewRouter will create a pointer to an endpoints struct and create all of its internal pointers
 func NewRouter(log *log.Logger) *Router {
 	routes := make(map[string]RouteConfiguration)
+	handles := make(map[string]HandleConfiguration)
+	groups := make(map[string]RouteGroup)
 
 	return &Router{
 		log:     log,
 		routes:  routes,
 		handles: handles,
+		groups:  groups,
 		started: false,
 	}
 }
@@ -67,7 +94,7 @@ func (r *Router) SetSecurity(certfile, keyfile string, tlsConfig *tls.Config) (e
 	defer r.Unlock()
 	//we re-create the pointers here, so we can "re-use" the router pointer if necessary
 	if r.started {
-		err = errors.New(ErrRouterStarted)
+		err = ErrRouterStarted
 		return
 	}
 	//Check the certs
@@ -95,10 +122,11 @@ func (r *Router) Start(addr, port string, routes []RouteConfiguration, handles [
 	defer r.Unlock()
 
 	var configListenAndServeWait time.Duration
+	var tlsConfig *tls.Config
 
 	//we re-create the pointers here, so we can "re-use" the router pointer if necessary
 	if r.started {
-		err = errors.New(ErrRouterStarted)
+		err = ErrRouterStarted
 		return
 	}
 	//validate and set configuration
@@ -108,28 +136,55 @@ func (r *Router) Start(addr, port string, routes []RouteConfiguration, handles [
 		configListenAndServeWait = DefaultListenAndServeWait
 	}
 
-	//create router
-	r.router = chi.NewRouter()
-	//Add middleware if have any
-	if len(r.middlewares) > 0 {
-		r.router.Use(r.middlewares...)
+	//the TLS configuration includes the client CAs
+	if tlsConfig, err = r.serverTLSConfig(); err != nil {
+		return
+	}
+	//remove what was built if the router can't start so that it can be started again
+	defer func() {
+		if err == nil {
+			return
+		}
+		if r.certReloader != nil {
+			r.certReloader.stop()
+			r.certReloader = nil
+		}
+		for _, route := range routes {
+			delete(r.routes, route.Route+route.Method)
+		}
+		for _, handle := range handles {
+			delete(r.handles, handle.Route)
+		}
+		r.router = nil
+	}()
+	//build the routes and handles into the router
+	r.buildRoutes(routes)
+	r.buildHandles(handles)
+	if err = r.rebuild(); err != nil {
+		return
 	}
-	//create rest server
+	//serve the certificate from its files so that it can be rotated without restarting
+	if r.usingSecurity {
+		if r.certReloader, err = newCertReloader(r.certfile, r.keyfile, r.clientCAFile, r.metrics, r.logln); err != nil {
+			return
+		}
+		tlsConfig = r.certReloader.tlsConfig(tlsConfig)
+		r.certReloader.watch(certReloadInterval())
+		r.logln("serving certificate " + r.certfile + ", " + certExpiry(r.certReloader.certificate.Load().(*tls.Certificate).Leaf))
+	}
+	//create rest server, requests are served by the current router so it can be swapped while running
 	r.restServer = &http.Server{
 		Addr:      addr + ":" + port,
-		Handler:   r.router,
-		TLSConfig: r.tlsConfig,
+		Handler:   http.HandlerFunc(r.serveHTTP),
+		TLSConfig: tlsConfig,
 	}
-	//build the routes into the router
-	r.buildRoutes(routes)
-	//build the handles into the router
-	r.buildHandles(handles)
 	//launch the server
 	r.LaunchServer()
 	//wait for the server to start running successfully
 	<-time.After(configListenAndServeWait)
 	//set started to true
 	r.started = true
+	atomic.StoreInt32(&r.readyState, 1)
 
 	return
 }
@@ -145,9 +200,11 @@ func (r *Router) Stop() (err error) {
 
 	//check if the rest server is actually started
 	if !r.started {
-		err = errors.New(ErrRouterNotStarted)
+		err = ErrRouterNotStarted
 		return
 	}
+	//stop being ready while draining
+	atomic.StoreInt32(&r.readyState, 0)
 	//validate and set configuration
 	if ConfigServerShutdownTimeout > 0 {
 		configServerShutdownTimeout = ConfigListenAndServeWait
@@ -157,17 +214,31 @@ func (r *Router) Stop() (err error) {
 	//create context with cancel
 	ctx, cancel := context.WithTimeout(context.Background(), configServerShutdownTimeout)
 	defer cancel()
-	//shutdown the rest server
+	//shutdown the rest server, the connections still active after the timeout are closed so that
+	// the router is stopped anyway, the error of the shutdown is returned once cleaned up
 	if err = r.restServer.Shutdown(ctx); err != nil {
-		return
+		r.restServer.Close()
 	}
 	//wait for the server to return with r.Done()
 	r.Wait()
+	//stop watching the certificate
+	if r.certReloader != nil {
+		r.certReloader.stop()
+		r.certReloader = nil
+	}
 	//range through routes and delete
 	for key := range r.routes {
 		//delete from map
 		delete(r.routes, key)
 	}
+	//range through handles and delete
+	for key := range r.handles {
+		delete(r.handles, key)
+	}
+	//range through groups and delete
+	for key := range r.groups {
+		delete(r.groups, key)
+	}
 	//remove rest server and router
 	r.restServer, r.router = nil, nil
 	//set started to false to signify that router has been stopped
@@ -185,7 +256,7 @@ func (r *Router) Close() (err error) {
 
 	//only unset internal pointers if not started
 	if r.started {
-		err = errors.New(ErrRouterStarted)
+		err = ErrRouterStarted
 
 		return
 	}
@@ -193,7 +264,7 @@ func (r *Router) Close() (err error) {
 	//set internal configuration to defaults
 	r.started = false
 	//set internal pointers to nil
-	r.router, r.restServer, r.routes, r.handles = nil, nil, nil, nil
+	r.router, r.restServer, r.routes, r.handles, r.groups = nil, nil, nil, nil, nil
 	r.middlewares = nil
 	return
 }
@@ -211,7 +282,12 @@ func (r *Router) LaunchServer() {
 		// with the commented out code below:
 		// if err := r.restServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		if r.usingSecurity {
-			if err := r.restServer.ListenAndServeTLS(r.certfile, r.keyfile); err != nil {
+			//the certificate is given by the TLS configuration when it's reloaded
+			certfile, keyfile := r.certfile, r.keyfile
+			if r.certReloader != nil {
+				certfile, keyfile = "", ""
+			}
+			if err := r.restServer.ListenAndServeTLS(certfile, keyfile); err != nil {
 				r.logln(err.Error())
 			}
 		} else {
@@ -222,34 +298,218 @@ func (r *Router) LaunchServer() {
 	}()
 }
 
-//buildRoutes creates routes for the REST server
+//buildRoutes adds routes to the internal map, they're added to the router by rebuild
 func (r *Router) buildRoutes(routes []RouteConfiguration) {
 	//strong assumption that routes have already been validated
 	//add all the routes to the internal map
 	for _, route := range routes {
 		r.routes[route.Route+route.Method] = route
 	}
-	//add all of the configurations to the router
-	for _, route := range routes {
-		r.router.MethodFunc(route.Method, route.Route, route.HandleFx)
-	}
 }
 
-//buildRoutes creates routes for the REST server
+//buildHandles adds handles to the internal map, they're added to the router by rebuild
 func (r *Router) buildHandles(handles []HandleConfiguration) {
-	//strong assumption that routes have already been validated
-	//add all the routes to the internal map
+	//strong assumption that handles have already been validated
+	//add all the handles to the internal map
 	for _, handle := range handles {
 		r.handles[handle.Route] = handle
 	}
-	//add all of the configurations to the router
+}
+
+//rebuild creates a new router from the internal maps and swaps it with the router serving requests,
+// the current router keeps serving if the new one can't be built, must be called with the lock held
+func (r *Router) rebuild() (err error) {
+	var routes []RouteConfiguration
+	var handles []HandleConfiguration
+	var groups []RouteGroup
+
+	for _, route := range r.routes {
+		routes = append(routes, route)
+	}
+	for _, handle := range r.handles {
+		handles = append(handles, handle)
+	}
+	for _, group := range r.groups {
+		groups = append(groups, group)
+	}
+	//routes requiring scopes or roles fail closed rather than being served to anyone
+	if r.authenticator == nil {
+		groupRoutes, _ := FlattenGroups(groups)
+		for _, route := range append(append([]RouteConfiguration(nil), routes...), groupRoutes...) {
+			if len(route.Scopes) > 0 || len(route.Roles) > 0 {
+				err = ErrNoAuthenticatorf.Withf(route.Method, route.Route)
+
+				return
+			}
+		}
+	}
+	//chi panics on invalid or conflicting patterns
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = ErrRouterBuildf.Withf(recovered)
+		}
+	}()
+	//create router
+	router := chi.NewRouter()
+	//respond with problems rather than plain text
+	router.NotFound(NotFound)
+	router.MethodNotAllowed(MethodNotAllowed)
+	//record metrics first so that the time spent in the other middlewares is included
+	if r.metrics != nil {
+		r.seedMetrics(routes, groups)
+		router.Use(r.metrics.Middleware)
+	}
+	//Add middleware if have any
+	if len(r.middlewares) > 0 {
+		router.Use(r.middlewares...)
+	}
+	//add all of the configurations to the router, routes without variables first
+	for _, route := range SortRoutes(routes) {
+		router.MethodFunc(route.Method, route.Route, r.handleFx(route))
+	}
+	buildHandles(router, SortHandles(handles))
+	//add the health handles if a health checker is set
+	if r.healthChecker != nil {
+		router.MethodFunc(http.MethodGet, HealthLivePath, r.live)
+		router.MethodFunc(http.MethodGet, HealthReadyPath, r.ready)
+	}
+	//add the metrics route if metrics are set
+	if r.metrics != nil {
+		router.Method(http.MethodGet, r.metricsRoute, r.metrics)
+	}
+	//add the OpenAPI document of the routes if set
+	if r.openAPI != nil {
+		if err = r.buildOpenAPI(router, routes, groups); err != nil {
+			return
+		}
+	}
+	//add the groups as sub routers with their own middlewares
+	r.buildGroups(router, SortGroups(groups))
+	r.router = router
+	r.mux.Store(router)
+
+	return
+}
+
+//buildHandles adds handles to a router
+func buildHandles(router chi.Router, handles []HandleConfiguration) {
 	for _, handle := range handles {
 		if !handle.Pattern {
-			r.router.Handle(handle.Route, handle.HandleFx)
+			router.Handle(handle.Route, handle.HandleFx)
 		} else {
-			r.router.Mount(handle.Route, handle.HandleFx)
+			router.Mount(handle.Route, handle.HandleFx)
+		}
+	}
+}
+
+//buildGroups adds groups to a router as sub routers, the middlewares of a group only apply to its
+// routes, handles and nested groups
+func (r *Router) buildGroups(router chi.Router, groups []RouteGroup) {
+	for _, group := range groups {
+		group := group
+		router.Route(group.Prefix, func(sub chi.Router) {
+			if len(group.Middlewares) > 0 {
+				sub.Use(group.Middlewares...)
+			}
+			for _, route := range group.Routes {
+				sub.MethodFunc(route.Method, route.Route, r.handleFx(route))
+			}
+			buildHandles(sub, group.Handles)
+			r.buildGroups(sub, group.Groups)
+		})
+	}
+}
+
+//isReady returns whether or not the router has started and isn't stopping
+func (r *Router) isReady() bool {
+	return atomic.LoadInt32(&r.readyState) == 1
+}
+
+//serveHTTP serves a request with the current router
+func (r *Router) serveHTTP(writer http.ResponseWriter, request *http.Request) {
+	r.mux.Load().(*chi.Mux).ServeHTTP(writer, request)
+}
+
+//AddRoutes adds routes to the router, routes added while the router is started take effect
+// immediately without dropping connections
+func (r *Router) AddRoutes(routes ...RouteConfiguration) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if err = ValidateRoutes(routes); err != nil {
+		return
+	}
+	for _, route := range routes {
+		if _, ok := r.routes[route.Route+route.Method]; ok {
+			err = ErrRouteExistsf.Withf(route.Method, route.Route)
+
+			return
+		}
+	}
+	r.buildRoutes(routes)
+	if !r.started {
+		return
+	}
+	if err = r.rebuild(); err != nil {
+		//remove the routes again so the maps match the router
+		for _, route := range routes {
+			delete(r.routes, route.Route+route.Method)
+		}
+	}
+
+	return
+}
+
+//RemoveRoute removes the route of the method from the router, a route removed while the router is
+// started stops being served immediately
+func (r *Router) RemoveRoute(method, route string) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	removed, ok := r.routes[route+method]
+	if !ok {
+		err = ErrRouteNotFoundf.Withf(method, route)
+
+		return
+	}
+	delete(r.routes, route+method)
+	if r.started {
+		if err = r.rebuild(); err != nil {
+			r.routes[route+method] = removed
+
+			return
 		}
 	}
+	//a removed route is no longer reported
+	r.unseedMetrics([]RouteConfiguration{removed}, nil)
+
+	return
+}
+
+//ReplaceHandle replaces the handle of the same route, a handle replaced while the router is started
+// takes effect immediately
+func (r *Router) ReplaceHandle(handle HandleConfiguration) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if err = ValidateHandles([]HandleConfiguration{handle}); err != nil {
+		return
+	}
+	replaced, ok := r.handles[handle.Route]
+	if !ok {
+		err = ErrHandleNotFoundf.Withf(handle.Route)
+
+		return
+	}
+	r.handles[handle.Route] = handle
+	if !r.started {
+		return
+	}
+	if err = r.rebuild(); err != nil {
+		r.handles[handle.Route] = replaced
+	}
+
+	return
 }
 
 //logln can be used to write to the log provided at startup
@@ -258,3 +518,61 @@ func (r *Router) logln(info string) {
 		r.log.Println(LogPrefix + info)
 	}
 }
+
+//AddGroups adds groups to the router, groups added while the router is started take effect
+// immediately without dropping connections
+func (r *Router) AddGroups(groups ...RouteGroup) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if err = ValidateGroups(groups); err != nil {
+		return
+	}
+	for _, group := range groups {
+		if _, ok := r.groups[group.Prefix]; ok {
+			err = ErrGroupExistsf.Withf(group.Prefix)
+
+			return
+		}
+	}
+	for _, group := range groups {
+		r.groups[group.Prefix] = group
+	}
+	if !r.started {
+		return
+	}
+	if err = r.rebuild(); err != nil {
+		//remove the groups again so the maps match the router
+		for _, group := range groups {
+			delete(r.groups, group.Prefix)
+		}
+	}
+
+	return
+}
+
+//RemoveGroup removes the group with the prefix from the router, a group removed while the router
+// is started stops being served immediately
+func (r *Router) RemoveGroup(prefix string) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	removed, ok := r.groups[prefix]
+	if !ok {
+		err = ErrGroupNotFoundf.Withf(prefix)
+
+		return
+	}
+	delete(r.groups, prefix)
+	if r.started {
+		if err = r.rebuild(); err != nil {
+			r.groups[prefix] = removed
+
+			return
+		}
+	}
+	//the routes of a removed group are no longer reported
+	r.unseedMetrics(nil, []RouteGroup{removed})
+
+	return
+}
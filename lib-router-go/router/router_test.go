@@ -0,0 +1,294 @@
+package router
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//useTestTimings shortens the waits of the router for the duration of the test
+func useTestTimings(t *testing.T) {
+	listenAndServeWait, shutdownTimeout, certWatchInterval := ConfigListenAndServeWait, ConfigServerShutdownTimeout, ConfigCertWatchInterval
+	ConfigListenAndServeWait, ConfigServerShutdownTimeout, ConfigCertWatchInterval = 100*time.Millisecond, 100*time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() {
+		ConfigListenAndServeWait, ConfigServerShutdownTimeout, ConfigCertWatchInterval = listenAndServeWait, shutdownTimeout, certWatchInterval
+	})
+}
+
+//freePort returns a port that's free to listen to
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+//writeCertificate writes a certificate and its key as PEM files
+func writeCertificate(t *testing.T, certfile, keyfile string, certificate *x509.Certificate, key *ecdsa.PrivateKey) {
+	t.Helper()
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyfile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(certfile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//servedSerial returns the serial of the certificate served at the address
+func servedSerial(address string, roots *x509.CertPool) (serial string, err error) {
+	conn, err := tls.Dial("tcp", address, &tls.Config{RootCAs: roots, ServerName: "localhost"})
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.String(), nil
+}
+
+func TestCertificateSwap(t *testing.T) {
+	useTestTimings(t)
+	dir := t.TempDir()
+	certfile, keyfile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ca, caKey := newTestCertificate(t, "ca", nil, nil, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	first, firstKey := newTestCertificate(t, "localhost", []string{"localhost"}, ca, caKey)
+	writeCertificate(t, certfile, keyfile, first, firstKey)
+
+	r := NewRouter(nil)
+	if err := r.SetSecurity(certfile, keyfile, nil); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	if err := r.Start("127.0.0.1", port, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	address := "127.0.0.1:" + port
+	if serial, err := servedSerial(address, roots); err != nil || serial != first.SerialNumber.String() {
+		t.Fatalf("served certificate %s, %v", serial, err)
+	}
+	//the certificate is swapped once its files change
+	second, secondKey := newTestCertificate(t, "localhost", []string{"localhost"}, ca, caKey)
+	writeCertificate(t, certfile, keyfile, second, secondKey)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		serial, err := servedSerial(address, roots)
+		if err == nil && serial == second.SerialNumber.String() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("certificate not swapped, served %s, %v", serial, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	//the certificate is kept while its files don't match
+	third, _ := newTestCertificate(t, "localhost", []string{"localhost"}, ca, caKey)
+	writeCertificate(t, certfile, keyfile, third, secondKey)
+	time.Sleep(200 * time.Millisecond)
+	if serial, err := servedSerial(address, roots); err != nil || serial != second.SerialNumber.String() {
+		t.Errorf("served certificate %s, %v after an invalid change", serial, err)
+	}
+}
+
+func TestStartFailureCleansUp(t *testing.T) {
+	useTestTimings(t)
+	route := RouteConfiguration{
+		Route:    "/secured",
+		Method:   http.MethodGet,
+		HandleFx: func(http.ResponseWriter, *http.Request) {},
+		Scopes:   []string{"write"},
+	}
+	handle := HandleConfiguration{Route: "/files", HandleFx: http.NotFoundHandler()}
+
+	r := NewRouter(nil)
+	if err := r.Start("127.0.0.1", freePort(t), []RouteConfiguration{route}, []HandleConfiguration{handle}); !errors.Is(err, ErrNoAuthenticatorf) {
+		t.Fatalf("started with %v", err)
+	}
+	if len(r.routes) != 0 || len(r.handles) != 0 || r.started {
+		t.Errorf("failed start left %d routes, %d handles, started %t", len(r.routes), len(r.handles), r.started)
+	}
+	//a certificate that can't be loaded anymore fails the start
+	dir := t.TempDir()
+	certfile, keyfile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	certificate, key := newTestCertificate(t, "localhost", []string{"localhost"}, nil, nil)
+	writeCertificate(t, certfile, keyfile, certificate, key)
+	if err := r.SetSecurity(certfile, keyfile, nil); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(certfile)
+	route.Scopes = nil
+	if err := r.Start("127.0.0.1", freePort(t), []RouteConfiguration{route}, []HandleConfiguration{handle}); !errors.Is(err, ErrCertificatef) {
+		t.Fatalf("started with %v", err)
+	}
+	if len(r.routes) != 0 || len(r.handles) != 0 || r.certReloader != nil || r.started {
+		t.Errorf("failed start left %d routes, %d handles, started %t", len(r.routes), len(r.handles), r.started)
+	}
+	writeCertificate(t, certfile, keyfile, certificate, key)
+	if err := r.Start("127.0.0.1", freePort(t), []RouteConfiguration{route}, []HandleConfiguration{handle}); err != nil {
+		t.Fatalf("not started after a failed start: %v", err)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStopFailureCleansUp(t *testing.T) {
+	useTestTimings(t)
+	dir := t.TempDir()
+	certfile, keyfile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ca, caKey := newTestCertificate(t, "ca", nil, nil, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	certificate, key := newTestCertificate(t, "localhost", []string{"localhost"}, ca, caKey)
+	writeCertificate(t, certfile, keyfile, certificate, key)
+	//a request served past the shutdown timeout fails the shutdown
+	served, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	route := RouteConfiguration{
+		Route:  "/slow",
+		Method: http.MethodGet,
+		HandleFx: func(http.ResponseWriter, *http.Request) {
+			close(served)
+			<-release
+		},
+	}
+
+	r := NewRouter(nil)
+	if err := r.SetSecurity(certfile, keyfile, nil); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	if err := r.Start("127.0.0.1", port, []RouteConfiguration{route}, nil); err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost"}}}
+	go client.Get("https://127.0.0.1:" + port + "/slow")
+	<-served
+	if err := r.Stop(); err == nil {
+		t.Fatal("stopped while serving a request")
+	}
+	if r.started || r.isReady() || r.certReloader != nil || len(r.routes) != 0 {
+		t.Errorf("failed stop left the router started %t, ready %t, watching %t", r.started, r.isReady(), r.certReloader != nil)
+	}
+	if err := r.Start("127.0.0.1", port, nil, nil); err != nil {
+		t.Fatalf("not started after a failed stop: %v", err)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//startedRouter returns a router serving the routes and handles as if it was started, without listening
+func startedRouter(t *testing.T, routes []RouteConfiguration, handles []HandleConfiguration) *Router {
+	t.Helper()
+
+	r := NewRouter(nil)
+	r.Lock()
+	defer r.Unlock()
+
+	r.buildRoutes(routes)
+	r.buildHandles(handles)
+	if err := r.rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	r.started = true
+
+	return r
+}
+
+//serveStatus returns the status of a request served by the function, e.g. the serveHTTP of a router
+func serveStatus(serve func(http.ResponseWriter, *http.Request), method, path string) int {
+	recorder := httptest.NewRecorder()
+	serve(recorder, httptest.NewRequest(method, path, nil))
+
+	return recorder.Code
+}
+
+func TestAddRoutes(t *testing.T) {
+	r := startedRouter(t, nil, nil)
+	route := RouteConfiguration{Route: "/items", Method: http.MethodGet, HandleFx: noContent}
+
+	if status := serveStatus(r.serveHTTP, http.MethodGet, "/items"); status != http.StatusNotFound {
+		t.Errorf("route served with %d before it's added", status)
+	}
+	if err := r.AddRoutes(route); err != nil {
+		t.Fatal(err)
+	}
+	if status := serveStatus(r.serveHTTP, http.MethodGet, "/items"); status != http.StatusNoContent {
+		t.Errorf("added route served with %d", status)
+	}
+	if err := r.AddRoutes(route); !errors.Is(err, ErrRouteExistsf) {
+		t.Errorf("route added twice with %v", err)
+	}
+	if err := r.AddRoutes(RouteConfiguration{Route: "/items", Method: "FETCH", HandleFx: noContent}); !errors.Is(err, ErrInvalidMethod) {
+		t.Errorf("invalid route added with %v", err)
+	}
+	//a route that can't be built isn't kept
+	if err := r.AddRoutes(RouteConfiguration{Route: "items", Method: http.MethodPost, HandleFx: noContent}); !errors.Is(err, ErrRouterBuildf) {
+		t.Errorf("route that can't be built added with %v", err)
+	}
+	if _, ok := r.routes["items"+http.MethodPost]; ok {
+		t.Error("route that can't be built kept")
+	}
+	if status := serveStatus(r.serveHTTP, http.MethodGet, "/items"); status != http.StatusNoContent {
+		t.Errorf("route served with %d after a failed add", status)
+	}
+}
+
+func TestRemoveRoute(t *testing.T) {
+	r := startedRouter(t, []RouteConfiguration{
+		{Route: "/items", Method: http.MethodGet, HandleFx: noContent},
+		{Route: "/items", Method: http.MethodPost, HandleFx: noContent},
+	}, nil)
+
+	if err := r.RemoveRoute(http.MethodPost, "/items"); err != nil {
+		t.Fatal(err)
+	}
+	if status := serveStatus(r.serveHTTP, http.MethodPost, "/items"); status != http.StatusMethodNotAllowed {
+		t.Errorf("removed route served with %d", status)
+	}
+	if status := serveStatus(r.serveHTTP, http.MethodGet, "/items"); status != http.StatusNoContent {
+		t.Errorf("route of another method served with %d", status)
+	}
+	if err := r.RemoveRoute(http.MethodPost, "/items"); !errors.Is(err, ErrRouteNotFoundf) {
+		t.Errorf("route removed twice with %v", err)
+	}
+}
+
+func TestReplaceHandle(t *testing.T) {
+	r := startedRouter(t, nil, []HandleConfiguration{{Route: "/files", HandleFx: http.HandlerFunc(noContent)}})
+
+	if err := r.ReplaceHandle(HandleConfiguration{Route: "/files", HandleFx: http.NotFoundHandler()}); err != nil {
+		t.Fatal(err)
+	}
+	if status := serveStatus(r.serveHTTP, http.MethodGet, "/files"); status != http.StatusNotFound {
+		t.Errorf("replaced handle served with %d", status)
+	}
+	if err := r.ReplaceHandle(HandleConfiguration{Route: "/other", HandleFx: http.NotFoundHandler()}); !errors.Is(err, ErrHandleNotFoundf) {
+		t.Errorf("unknown handle replaced with %v", err)
+	}
+	if err := r.ReplaceHandle(HandleConfiguration{Route: "/files"}); !errors.Is(err, ErrHandleFxNil) {
+		t.Errorf("handle replaced by a nil handle with %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package router
+
+//---------------------------------------------------------------------------------------------------
+// watch.go
+//---------------------------------------------------------------------------------------------------
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+//DefaultWatchInterval is how often watched files are checked for changes by default
+const DefaultWatchInterval time.Duration = 30 * time.Second
+
+//fileStamp identifies the content of a file without reading it
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+//watcher polls files and calls a function when any of them changes, polling is used rather than
+// file system events so that files replaced through symbolic links, e.g. mounted secrets, are seen
+type watcher struct {
+	sync.WaitGroup                      //waitgroup to track the polling goRoutine
+	paths          []string             //files to watch
+	interval       time.Duration        //how often the files are checked
+	onChange       func() error         //called when a file changes
+	stamps         map[string]fileStamp //last stamps of the files
+	stopper        chan struct{}        //closed to stop polling
+	stopOnce       sync.Once            //closes the stopper once so that stop can be called more than once
+}
+
+//newWatcher creates a watcher of the files, the current stamps are taken so that only later changes
+// call the function, a change is seen again on the next check if the function fails, e.g. because
+// only some of the files have been written
+func newWatcher(interval time.Duration, onChange func() error, paths ...string) (w *watcher) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	w = &watcher{
+		paths:    paths,
+		interval: interval,
+		onChange: onChange,
+		stopper:  make(chan struct{}),
+	}
+	w.stamps = w.stat()
+
+	return
+}
+
+//stat returns the stamps of the files, files that can't be read have an empty stamp
+func (w *watcher) stat() map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(w.paths))
+	for _, path := range w.paths {
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+
+	return stamps
+}
+
+//check calls the function if a file changed since the last successful call
+func (w *watcher) check() {
+	stamps := w.stat()
+	if !changed(w.stamps, stamps) {
+		return
+	}
+	if err := w.onChange(); err == nil {
+		w.stamps = stamps
+	}
+}
+
+//changed returns whether or not the stamps of the files differ
+func changed(previous, current map[string]fileStamp) bool {
+	if len(previous) != len(current) {
+		return true
+	}
+	for path, stamp := range current {
+		if before, ok := previous[path]; !ok || !before.modTime.Equal(stamp.modTime) || before.size != stamp.size {
+			return true
+		}
+	}
+
+	return false
+}
+
+//LaunchWatch starts polling the files
+func (w *watcher) LaunchWatch() {
+	started := make(chan struct{})
+	w.Add(1)
+	go w.goWatch(started)
+	<-started
+}
+
+//goWatch - Creates a routine to periodically check the files
+func (w *watcher) goWatch(started chan struct{}) {
+	defer w.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	close(started)
+
+	for {
+		select {
+		case <-w.stopper:
+			return
+
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+//stop stops polling the files and waits for the polling goRoutine to return, it can be called more
+// than once
+func (w *watcher) stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopper)
+	})
+	w.Wait()
+}